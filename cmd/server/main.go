@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/handler"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/provider"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/usecase"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
@@ -31,13 +33,41 @@ func main() {
 	}
 	defer redisClient.Close()
 
+	// Load the token signing keys before serving any request
+	keyRepo := repository.NewKeyRepository(db)
+	keyManager := usecase.NewKeyManager(*keyRepo)
+	if err := keyManager.Load(); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	go keyManager.Run(context.Background())
+
+	// Configure the identity providers users can log in with
+	providers, err := provider.NewRegistryFromConfig()
+	if err != nil {
+		log.Fatalf("Failed to configure identity providers: %v", err)
+	}
+
 	// Define module
 	userRepo := repository.NewUserRepository(db)
-	authUseCase := usecase.NewAuthUseCase(*userRepo, redisClient)
+	tokenRepo := repository.NewTokenRepository(redisClient)
+	sessionRepo := repository.NewSessionRepository(redisClient)
+	authUseCase := usecase.NewAuthUseCase(*userRepo, *tokenRepo, *sessionRepo, providers)
 	authHandler := handler.NewAuthHandler(*authUseCase)
 
+	clientRepo := repository.NewClientRepository(db)
+	consentRepo := repository.NewConsentRepository(db)
+	oauthUseCase := usecase.NewOAuthUseCase(*userRepo, *clientRepo, *tokenRepo, *consentRepo)
+	oauthHandler := handler.NewOAuthHandler(*oauthUseCase)
+	clientUseCase := usecase.NewClientUseCase(*clientRepo)
+	clientHandler := handler.NewClientHandler(*clientUseCase)
+	discoveryHandler := handler.NewDiscoveryHandler()
+	keyHandler := handler.NewKeyHandler(*keyManager)
+
+	revocations := repository.NewRevocationCache(redisClient)
+	go revocations.Run(context.Background())
+
 	router := gin.Default()
-	routes.SetupRoutes(router, authHandler, redisClient)
+	routes.SetupRoutes(router, authHandler, oauthHandler, clientHandler, discoveryHandler, keyHandler, redisClient, revocations)
 
 	// Start the server
 	log.Printf("Server started on :%s", config.GetEnv("PORT"))
@@ -1,5 +1,7 @@
 package dto
 
+import "time"
+
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=8"`
@@ -11,7 +13,27 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required,min=8"`
 }
 
-type GoogleCallbackResponse struct {
+// BrowserLoginRequest is the form of the login page the authorization endpoint sends browsers to.
+type BrowserLoginRequest struct {
+	Email    string `form:"email" binding:"required,email"`
+	Password string `form:"password" binding:"required"`
+	ReturnTo string `form:"return_to" binding:"required"`
+}
+
+type LoginCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type ProviderUserResponse struct {
 	Email string `json:"email"`
 	Name  string `json:"name"`
 }
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
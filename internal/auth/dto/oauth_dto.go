@@ -0,0 +1,174 @@
+package dto
+
+import "encoding/json"
+
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	Nonce               string `form:"nonce"`
+	Prompt              string `form:"prompt"`
+	Request             string `form:"request"`
+	RequestURI          string `form:"request_uri"`
+}
+
+// PushedAuthorizationResponse follows RFC 9126 section 2.2.
+type PushedAuthorizationResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int64  `json:"expires_in"`
+}
+
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	DeviceCode   string `form:"device_code"`
+
+	// Token exchange (RFC 8693)
+	SubjectToken       string `form:"subject_token"`
+	SubjectTokenType   string `form:"subject_token_type"`
+	ActorToken         string `form:"actor_token"`
+	ActorTokenType     string `form:"actor_token_type"`
+	RequestedTokenType string `form:"requested_token_type"`
+	Audience           string `form:"audience"`
+
+	// DPoPKeyThumbprint is set by the handler from a verified DPoP header, never from the form.
+	DPoPKeyThumbprint string `form:"-"`
+}
+
+type TokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
+}
+
+type DeviceAuthorizationRequest struct {
+	Scope string `form:"scope"`
+}
+
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+type DeviceVerificationRequest struct {
+	UserCode string `json:"user_code" form:"user_code" binding:"required"`
+	Approve  bool   `json:"approve" form:"approve"`
+}
+
+type DeviceVerificationResponse struct {
+	UserCode   string `json:"user_code"`
+	ClientID   string `json:"client_id"`
+	ClientName string `json:"client_name"`
+	Scope      string `json:"scope"`
+}
+
+type ConsentRequestResponse struct {
+	ConsentID  string   `json:"consent_id"`
+	ClientID   string   `json:"client_id"`
+	ClientName string   `json:"client_name"`
+	Scopes     []string `json:"scopes"`
+}
+
+type ConsentDecisionRequest struct {
+	ConsentID string `json:"consent_id" form:"consent_id" binding:"required"`
+	Approve   bool   `json:"approve" form:"approve"`
+}
+
+type ConsentDecisionResponse struct {
+	RedirectURI string `json:"redirect_uri"`
+}
+
+type IntrospectionRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+}
+
+type RevocationRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+}
+
+// IntrospectionResponse follows RFC 7662. Inactive tokens only carry "active": false.
+type IntrospectionResponse struct {
+	Active    bool                   `json:"active"`
+	Scope     string                 `json:"scope,omitempty"`
+	ClientID  string                 `json:"client_id,omitempty"`
+	Sub       string                 `json:"sub,omitempty"`
+	Aud       Audience               `json:"aud,omitempty"`
+	Act       map[string]interface{} `json:"act,omitempty"`
+	Cnf       map[string]interface{} `json:"cnf,omitempty"`
+	TokenType string                 `json:"token_type,omitempty"`
+	Exp       int64                  `json:"exp,omitempty"`
+	Iat       int64                  `json:"iat,omitempty"`
+}
+
+// Audience is the aud of a token: a single string, or an array when the token has several
+// audiences (RFC 7519 section 4.1.3).
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = Audience(multiple)
+	return nil
+}
+
+// ClientRegistrationRequest carries the client metadata of RFC 7591 section 2.
+type ClientRegistrationRequest struct {
+	ClientID                string   `json:"client_id"`
+	ClientName              string   `json:"client_name"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	Scope                   string   `json:"scope"`
+
+	JWKS                               json.RawMessage `json:"jwks,omitempty"`
+	RequirePushedAuthorizationRequests bool            `json:"require_pushed_authorization_requests"`
+}
+
+type ClientRegistrationResponse struct {
+	ClientID                string   `json:"client_id"`
+	ClientSecret            string   `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64    `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   *int64   `json:"client_secret_expires_at,omitempty"`
+	RegistrationAccessToken string   `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string   `json:"registration_client_uri"`
+	ClientName              string   `json:"client_name,omitempty"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	Scope                   string   `json:"scope,omitempty"`
+
+	JWKS                               json.RawMessage `json:"jwks,omitempty"`
+	RequirePushedAuthorizationRequests bool            `json:"require_pushed_authorization_requests"`
+}
@@ -0,0 +1,57 @@
+package entity
+
+import "time"
+
+type OAuthClient struct {
+	ID                      uint      `json:"id"`
+	ClientID                string    `json:"client_id"`
+	ClientSecret            string    `json:"-"`
+	Name                    string    `json:"name"`
+	RedirectURIs            []string  `json:"redirect_uris"`
+	GrantTypes              []string  `json:"grant_types"`
+	Scopes                  []string  `json:"scopes"`
+	TokenEndpointAuthMethod string    `json:"token_endpoint_auth_method"`
+	RegistrationAccessToken string    `json:"-"`
+	TokenExchangeAudiences  []string  `json:"token_exchange_audiences"`
+	CreatedAt               time.Time `json:"created_at"`
+
+	// JWKS is the client's public key set in JSON, used to verify its signed request objects
+	JWKS                               string `json:"jwks,omitempty"`
+	RequirePushedAuthorizationRequests bool   `json:"require_pushed_authorization_requests"`
+}
+
+// IsPublic reports whether the client has no secret and must rely on PKCE.
+func (c *OAuthClient) IsPublic() bool {
+	return c.ClientSecret == ""
+}
+
+func (c *OAuthClient) HasRedirectURI(redirectURI string) bool {
+	return contains(c.RedirectURIs, redirectURI)
+}
+
+func (c *OAuthClient) AllowsGrantType(grantType string) bool {
+	return contains(c.GrantTypes, grantType)
+}
+
+func (c *OAuthClient) AllowsScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowsTokenExchangeAudience reports whether the client may exchange tokens for ones targeted at audience.
+func (c *OAuthClient) AllowsTokenExchangeAudience(audience string) bool {
+	return contains(c.TokenExchangeAudiences, audience)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
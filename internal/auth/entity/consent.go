@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// Consent records the scopes a user has granted to a client.
+type Consent struct {
+	UserID    uint      `json:"user_id"`
+	ClientID  string    `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConsentRequest is an authorization request waiting for the user to approve the requested scopes.
+type ConsentRequest struct {
+	ID            string            `json:"id"`
+	Authorization AuthorizationCode `json:"authorization"`
+	State         string            `json:"state,omitempty"`
+	ExpiresAt     time.Time         `json:"expires_at"`
+}
@@ -1,7 +0,0 @@
-package entity
-
-type GoogleUserInfo struct {
-	Email    string `json:"email"`
-	Name     string `json:"name"`
-	GoogleID string `json:"id"`
-}
@@ -0,0 +1,86 @@
+package entity
+
+import "time"
+
+type AuthorizationCode struct {
+	ClientID            string    `json:"client_id"`
+	UserID              uint      `json:"user_id"`
+	RedirectURI         string    `json:"redirect_uri"`
+	Scope               string    `json:"scope"`
+	CodeChallenge       string    `json:"code_challenge"`
+	CodeChallengeMethod string    `json:"code_challenge_method"`
+	Nonce               string    `json:"nonce,omitempty"`
+	AuthTime            int64     `json:"auth_time,omitempty"`
+	ExpiresAt           time.Time `json:"expires_at"`
+}
+
+// RefreshToken is rotated on every use. All tokens descending from the same grant share
+// a FamilyID, so the whole chain can be revoked when a rotated token is presented again.
+// JKT is the thumbprint of the DPoP key the family is bound to, if any.
+type RefreshToken struct {
+	FamilyID  string    `json:"family_id"`
+	SessionID string    `json:"session_id,omitempty"`
+	ClientID  string    `json:"client_id"`
+	UserID    uint      `json:"user_id"`
+	Scope     string    `json:"scope"`
+	AuthTime  int64     `json:"auth_time,omitempty"`
+	JKT       string    `json:"jkt,omitempty"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Rotated is set when the token has already been exchanged for a new one
+	Rotated bool `json:"-"`
+}
+
+// PushedAuthorizationRequest holds the parameters of an authorization request pushed to the
+// PAR endpoint (RFC 9126) until the client redirects the user with its request_uri.
+type PushedAuthorizationRequest struct {
+	ClientID            string    `json:"client_id"`
+	ResponseType        string    `json:"response_type"`
+	RedirectURI         string    `json:"redirect_uri"`
+	Scope               string    `json:"scope"`
+	State               string    `json:"state,omitempty"`
+	CodeChallenge       string    `json:"code_challenge"`
+	CodeChallengeMethod string    `json:"code_challenge_method"`
+	Nonce               string    `json:"nonce,omitempty"`
+	Prompt              string    `json:"prompt,omitempty"`
+	ExpiresAt           time.Time `json:"expires_at"`
+}
+
+// LoginFlow is a login in progress at an upstream identity provider, keyed by its state.
+type LoginFlow struct {
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	ReturnTo     string    `json:"return_to,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// LoginCode hands a completed provider login to the frontend it returns to. The frontend
+// redeems it once for tokens, which are only issued at that point.
+type LoginCode struct {
+	UserID    uint      `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type DeviceAuthorization struct {
+	DeviceCodeHash string    `json:"device_code_hash"`
+	UserCode       string    `json:"user_code"`
+	ClientID       string    `json:"client_id"`
+	Scope          string    `json:"scope"`
+	Status         string    `json:"status"`
+	UserID         uint      `json:"user_id,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// DevicePoll is the polling state of a pending device flow. It is stored apart from the
+// DeviceAuthorization so that a poll never writes over the user's decision.
+type DevicePoll struct {
+	Interval     int       `json:"interval"`
+	LastPolledAt time.Time `json:"last_polled_at"`
+}
+
+const (
+	DeviceAuthorizationPending  = "pending"
+	DeviceAuthorizationApproved = "approved"
+	DeviceAuthorizationDenied   = "denied"
+)
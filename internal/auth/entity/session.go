@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// Session is one first-party login on a device. Each session holds its own refresh token family.
+type Session struct {
+	ID              string    `json:"id"`
+	UserID          uint      `json:"user_id"`
+	RefreshFamilyID string    `json:"refresh_family_id"`
+	UserAgent       string    `json:"user_agent"`
+	IPAddress       string    `json:"ip_address"`
+	JKT             string    `json:"jkt,omitempty"`
+	AuthTime        int64     `json:"auth_time"`
+	CreatedAt       time.Time `json:"created_at"`
+	LastUsedAt      time.Time `json:"last_used_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
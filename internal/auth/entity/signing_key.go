@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+type SigningKey struct {
+	ID          uint       `json:"id"`
+	KeyID       string     `json:"kid"`
+	Algorithm   string     `json:"alg"`
+	PrivateKey  string     `json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ActivatesAt time.Time  `json:"activates_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CanVerify reports whether tokens signed with the key are still accepted at the given time.
+func (k *SigningKey) CanVerify(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// CanSign reports whether the key may be picked to sign new tokens at the given time.
+func (k *SigningKey) CanSign(now time.Time) bool {
+	return k.CanVerify(now) && !now.Before(k.ActivatesAt)
+}
@@ -1,16 +1,18 @@
 package handler
 
 import (
+	"crypto/subtle"
+	"errors"
+	"log"
 	"net/http"
-	"sync"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/usecase"
-	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
 type AuthHandler struct {
@@ -21,43 +23,180 @@ func NewAuthHandler(authUseCase usecase.AuthUseCase) *AuthHandler {
 	return &AuthHandler{authUseCase: authUseCase}
 }
 
-var (
-	googleOauthConfig *oauth2.Config
-	once              sync.Once
-	randomState       = "random"
-)
+// loginStateCookie binds a provider login to the browser that started it, so a callback URL
+// crafted by someone else cannot log the browser into their account.
+const loginStateCookie = "login_state"
 
-func initGoogleOAuthConfig() {
-	once.Do(func() {
-		googleOauthConfig = &oauth2.Config{
-			ClientID:     config.GetEnv("GOOGLE_CLIENT_ID"),
-			ClientSecret: config.GetEnv("GOOGLE_CLIENT_SECRET"),
-			RedirectURL:  config.GetEnv("GOOGLE_REDIRECT_URL"),
-			Scopes:       []string{"openid", "profile", "email"},
-			Endpoint:     google.Endpoint,
+// ProviderLogin redirects the user to the login page of the identity provider in the path.
+// An optional return_to names the frontend page the callback sends the user back to.
+func (h *AuthHandler) ProviderLogin(c *gin.Context) {
+	url, state, err := h.authUseCase.StartProviderLogin(c.Param("provider"), c.Query("return_to"))
+	if err != nil {
+		if errors.Is(err, usecase.ErrProviderNotFound) {
+			utils.SendResponse(c, http.StatusNotFound, err.Error(), nil, true)
+			return
 		}
-	})
-}
+		if errors.Is(err, usecase.ErrInvalidReturnTo) {
+			utils.SendResponse(c, http.StatusBadRequest, err.Error(), nil, true)
+			return
+		}
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return
+	}
 
-func (h *AuthHandler) GoogleLogin(c *gin.Context) {
-	initGoogleOAuthConfig()
-	url := googleOauthConfig.AuthCodeURL(randomState)
+	setLoginStateCookie(c, state, int(usecase.LoginFlowTTL.Seconds()))
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
-func (h *AuthHandler) GoogleCallback(c *gin.Context) {
-	initGoogleOAuthConfig()
-
+func (h *AuthHandler) ProviderCallback(c *gin.Context) {
 	state := c.Query("state")
-	if state != randomState {
+	cookieState, _ := c.Cookie(loginStateCookie)
+	setLoginStateCookie(c, "", -1)
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
 		utils.SendResponse(c, http.StatusBadRequest, "Invalid state", nil, true)
 		return
 	}
 
+	flow, err := h.authUseCase.ConsumeLoginFlow(c.Param("provider"), state)
+	if err != nil {
+		if errors.Is(err, usecase.ErrProviderNotFound) {
+			utils.SendResponse(c, http.StatusNotFound, err.Error(), nil, true)
+			return
+		}
+		if errors.Is(err, usecase.ErrInvalidLoginFlow) {
+			utils.SendResponse(c, http.StatusBadRequest, err.Error(), nil, true)
+			return
+		}
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return
+	}
+
 	code := c.Query("code")
 
-	accessToken, refreshToken, user, err := h.authUseCase.HandleGoogleCallback(code, googleOauthConfig)
+	if flow.ReturnTo != "" && usecase.IsAuthorizationEndpointURL(flow.ReturnTo) {
+		h.returnToAuthorization(c, flow, code)
+		return
+	}
+	if flow.ReturnTo != "" {
+		h.returnToFrontend(c, flow, code)
+		return
+	}
+
+	info, ok := h.clientInfo(c)
+	if !ok {
+		return
+	}
+
+	accessToken, refreshToken, user, err := h.authUseCase.HandleProviderCallback(flow, code, info)
+	if err != nil {
+		if errors.Is(err, usecase.ErrAccountExists) {
+			utils.SendResponse(c, http.StatusConflict, err.Error(), nil, true)
+			return
+		}
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return
+	}
+
+	utils.SendResponse(c, http.StatusOK, "Login successful", gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"user":          user,
+	}, false)
+}
+
+// returnToFrontend ends a login started with return_to by redirecting there with a login_code,
+// or with an error when the login failed.
+func (h *AuthHandler) returnToFrontend(c *gin.Context, flow *entity.LoginFlow, code string) {
+	if c.Query("error") != "" || code == "" {
+		reason := "login_failed"
+		if c.Query("error") == "access_denied" {
+			reason = "access_denied"
+		}
+		c.Redirect(http.StatusSeeOther, usecase.ReturnToURL(flow.ReturnTo, "error", reason))
+		return
+	}
+
+	redirectURL, err := h.authUseCase.IssueLoginCode(flow, code)
+	if err != nil {
+		log.Printf("Login with %s failed: %v", flow.Provider, err)
+		reason := "login_failed"
+		if errors.Is(err, usecase.ErrAccountExists) {
+			reason = "account_exists"
+		}
+		redirectURL = usecase.ReturnToURL(flow.ReturnTo, "error", reason)
+	}
+	c.Redirect(http.StatusSeeOther, redirectURL)
+}
+
+// returnToAuthorization ends a login started by the authorization endpoint: the browser gets a
+// session cookie and resumes the authorization request it was interrupted in.
+func (h *AuthHandler) returnToAuthorization(c *gin.Context, flow *entity.LoginFlow, code string) {
+	if c.Query("error") != "" || code == "" {
+		utils.SendResponse(c, http.StatusBadRequest, "Login was not completed", nil, true)
+		return
+	}
+
+	secret, err := h.authUseCase.StartProviderBrowserSession(flow, code, browserClientInfo(c))
 	if err != nil {
+		if errors.Is(err, usecase.ErrAccountExists) {
+			utils.SendResponse(c, http.StatusConflict, err.Error(), nil, true)
+			return
+		}
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return
+	}
+
+	setSessionCookie(c, secret)
+	c.Redirect(http.StatusSeeOther, flow.ReturnTo)
+}
+
+// BrowserLogin is the form target of the login page (OAUTH_LOGIN_URI). It starts a browser
+// session and resumes the authorization request in return_to.
+func (h *AuthHandler) BrowserLogin(c *gin.Context) {
+	var req dto.BrowserLoginRequest
+	if err := c.ShouldBind(&req); err != nil {
+		utils.SendResponse(c, http.StatusBadRequest, err.Error(), nil, true)
+		return
+	}
+	if !usecase.IsAuthorizationEndpointURL(req.ReturnTo) {
+		utils.SendResponse(c, http.StatusBadRequest, usecase.ErrInvalidReturnTo.Error(), nil, true)
+		return
+	}
+
+	secret, err := h.authUseCase.LoginBrowser(req.Email, req.Password, browserClientInfo(c))
+	if err != nil {
+		if loginURI := usecase.LoginURI(); loginURI != "" {
+			c.Redirect(http.StatusSeeOther, usecase.ReturnToURL(usecase.ReturnToURL(loginURI, "return_to", req.ReturnTo), "error", "invalid_credentials"))
+			return
+		}
+		utils.SendResponse(c, http.StatusUnauthorized, "Invalid credentials", nil, true)
+		return
+	}
+
+	setSessionCookie(c, secret)
+	c.Redirect(http.StatusSeeOther, req.ReturnTo)
+}
+
+// RedeemLoginCode returns the tokens of a login that ended with a redirect to return_to.
+func (h *AuthHandler) RedeemLoginCode(c *gin.Context) {
+	var req dto.LoginCodeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(c, http.StatusBadRequest, err.Error(), nil, true)
+		return
+	}
+
+	info, ok := h.clientInfo(c)
+	if !ok {
+		return
+	}
+
+	accessToken, refreshToken, user, err := h.authUseCase.RedeemLoginCode(req.Code, info)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidLoginCode) {
+			utils.SendResponse(c, http.StatusBadRequest, err.Error(), nil, true)
+			return
+		}
 		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
 		return
 	}
@@ -69,6 +208,20 @@ func (h *AuthHandler) GoogleCallback(c *gin.Context) {
 	}, false)
 }
 
+// setSessionCookie keeps the browser logged in at the authorization endpoint. SameSite=Lax sends
+// it when a client redirects the browser there, but not with cross-site form posts.
+func setSessionCookie(c *gin.Context, secret string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(usecase.SessionCookieName, secret, int(utils.RefreshTokenExpiration().Seconds()), "/oauth", "", strings.HasPrefix(utils.Issuer(), "https://"), true)
+}
+
+// setLoginStateCookie scopes the cookie to the login routes. SameSite=Lax still sends it on the
+// provider's top-level redirect back to the callback.
+func setLoginStateCookie(c *gin.Context, state string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(loginStateCookie, state, maxAge, "/api/auth/login", "", strings.HasPrefix(utils.Issuer(), "https://"), true)
+}
+
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req dto.LoginRequest
 
@@ -77,7 +230,12 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	accessToken, refreshToken, err := h.authUseCase.Login(req.Email, req.Password)
+	info, ok := h.clientInfo(c)
+	if !ok {
+		return
+	}
+
+	accessToken, refreshToken, err := h.authUseCase.Login(req.Email, req.Password, info)
 	if err != nil {
 		utils.SendResponse(c, http.StatusUnauthorized, err.Error(), nil, true)
 		return
@@ -97,7 +255,12 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	accessToken, refreshToken, err := h.authUseCase.Register(req)
+	info, ok := h.clientInfo(c)
+	if !ok {
+		return
+	}
+
+	accessToken, refreshToken, err := h.authUseCase.Register(req, info)
 	if err != nil {
 		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
 		return
@@ -116,13 +279,7 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
-	accessToken := c.GetHeader("Authorization")
-	if accessToken == "" {
-		utils.SendResponse(c, http.StatusBadRequest, "Authorization header is required", nil, true)
-		return
-	}
-
-	if err := h.authUseCase.Logout(userID.(uint), accessToken); err != nil {
+	if err := h.authUseCase.Logout(userID.(uint), c.GetString("sessionID"), c.GetString("tokenID"), c.GetTime("tokenExpiresAt")); err != nil {
 		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
 		return
 	}
@@ -131,12 +288,6 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 }
 
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		utils.SendResponse(c, http.StatusUnauthorized, "Unauthorized", nil, true)
-		return
-	}
-
 	var req struct {
 		RefreshToken string `json:"refresh_token" binding:"required"`
 	}
@@ -145,13 +296,127 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	newAccessToken, err := h.authUseCase.RefreshToken(userID.(uint), req.RefreshToken)
+	jkt, ok := h.dpopKeyThumbprint(c)
+	if !ok {
+		return
+	}
+
+	newAccessToken, newRefreshToken, err := h.authUseCase.RefreshToken(req.RefreshToken, jkt)
 	if err != nil {
 		utils.SendResponse(c, http.StatusUnauthorized, err.Error(), nil, true)
 		return
 	}
 
 	utils.SendResponse(c, http.StatusOK, "Token refreshed successfully", gin.H{
-		"token": newAccessToken,
+		"token":         newAccessToken,
+		"refresh_token": newRefreshToken,
 	}, false)
 }
+
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.SendResponse(c, http.StatusUnauthorized, "Unauthorized", nil, true)
+		return
+	}
+
+	sessions, err := h.authUseCase.ListSessions(userID.(uint), c.GetString("sessionID"))
+	if err != nil {
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return
+	}
+
+	utils.SendResponse(c, http.StatusOK, "Sessions retrieved successfully", sessions, false)
+}
+
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.SendResponse(c, http.StatusUnauthorized, "Unauthorized", nil, true)
+		return
+	}
+
+	if err := h.authUseCase.RevokeSession(userID.(uint), c.Param("id")); err != nil {
+		if errors.Is(err, usecase.ErrSessionNotFound) {
+			utils.SendResponse(c, http.StatusNotFound, err.Error(), nil, true)
+			return
+		}
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return
+	}
+
+	utils.SendResponse(c, http.StatusOK, "Session revoked successfully", nil, false)
+}
+
+// RevokeOtherSessions logs the user out on every device except the one making the request.
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.SendResponse(c, http.StatusUnauthorized, "Unauthorized", nil, true)
+		return
+	}
+
+	sessionID := c.GetString("sessionID")
+	if sessionID == "" {
+		utils.SendResponse(c, http.StatusBadRequest, "Access token is not bound to a session", nil, true)
+		return
+	}
+
+	if err := h.authUseCase.RevokeOtherSessions(userID.(uint), sessionID); err != nil {
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return
+	}
+
+	utils.SendResponse(c, http.StatusOK, "Other sessions revoked successfully", nil, false)
+}
+
+// RevokeUserTokens is an admin action that logs the user out everywhere.
+func (h *AuthHandler) RevokeUserTokens(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.SendResponse(c, http.StatusBadRequest, "Invalid user ID", nil, true)
+		return
+	}
+
+	if err := h.authUseCase.RevokeAllTokens(uint(userID)); err != nil {
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return
+	}
+
+	utils.SendResponse(c, http.StatusOK, "All tokens of the user revoked successfully", nil, false)
+}
+
+// clientInfo describes the device making the request. It writes the error response and
+// returns false when the request carries an invalid DPoP proof.
+func (h *AuthHandler) clientInfo(c *gin.Context) (usecase.ClientInfo, bool) {
+	jkt, ok := h.dpopKeyThumbprint(c)
+	if !ok {
+		return usecase.ClientInfo{}, false
+	}
+	return usecase.ClientInfo{
+		UserAgent:         c.Request.UserAgent(),
+		IPAddress:         c.ClientIP(),
+		DPoPKeyThumbprint: jkt,
+	}, true
+}
+
+// browserClientInfo describes a browser, which cannot hold a DPoP key for its session cookie.
+func browserClientInfo(c *gin.Context) usecase.ClientInfo {
+	return usecase.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
+
+func (h *AuthHandler) dpopKeyThumbprint(c *gin.Context) (string, bool) {
+	jkt, err := dpopKeyThumbprint(c, h.authUseCase.VerifyDPoPProof)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidDPoPProof) {
+			utils.SendResponse(c, http.StatusBadRequest, err.Error(), nil, true)
+			return "", false
+		}
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return "", false
+	}
+	return jkt, true
+}
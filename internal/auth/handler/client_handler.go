@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/usecase"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+type ClientHandler struct {
+	clientUseCase usecase.ClientUseCase
+}
+
+func NewClientHandler(clientUseCase usecase.ClientUseCase) *ClientHandler {
+	return &ClientHandler{clientUseCase: clientUseCase}
+}
+
+func (h *ClientHandler) Register(c *gin.Context) {
+	if err := h.clientUseCase.AuthorizeRegistration(bearerToken(c)); err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	var req dto.ClientRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendOAuthError(c, http.StatusBadRequest, "invalid_client_metadata", err.Error())
+		return
+	}
+
+	resp, err := h.clientUseCase.Register(req)
+	if err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *ClientHandler) GetClient(c *gin.Context) {
+	resp, err := h.clientUseCase.FindRegisteredClient(c.Param("client_id"), bearerToken(c))
+	if err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *ClientHandler) UpdateClient(c *gin.Context) {
+	var req dto.ClientRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendOAuthError(c, http.StatusBadRequest, "invalid_client_metadata", err.Error())
+		return
+	}
+
+	resp, err := h.clientUseCase.UpdateRegisteredClient(c.Param("client_id"), bearerToken(c), req)
+	if err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *ClientHandler) DeleteClient(c *gin.Context) {
+	if err := h.clientUseCase.DeleteRegisteredClient(c.Param("client_id"), bearerToken(c)); err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func bearerToken(c *gin.Context) string {
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/usecase"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+type DiscoveryHandler struct{}
+
+func NewDiscoveryHandler() *DiscoveryHandler {
+	return &DiscoveryHandler{}
+}
+
+func (h *DiscoveryHandler) OpenIDConfiguration(c *gin.Context) {
+	issuer := utils.Issuer()
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, dto.OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		UserInfoEndpoint:                  issuer + "/userinfo",
+		DeviceAuthorizationEndpoint:       issuer + "/oauth/device_authorization",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		RevocationEndpoint:                issuer + "/oauth/revoke",
+		RegistrationEndpoint:              issuer + "/oauth/register",
+		ScopesSupported:                   usecase.SupportedScopes(),
+		ResponseTypesSupported:            []string{usecase.ResponseTypeCode},
+		GrantTypesSupported:               []string{usecase.GrantTypeAuthorizationCode, usecase.GrantTypeRefreshToken, usecase.GrantTypeClientCredentials, usecase.GrantTypeDeviceCode, usecase.GrantTypeTokenExchange},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  utils.SupportedSigningAlgorithms,
+		TokenEndpointAuthMethodsSupported: []string{usecase.AuthMethodClientSecretBasic, usecase.AuthMethodClientSecretPost, usecase.AuthMethodNone},
+		CodeChallengeMethodsSupported:     []string{usecase.CodeChallengeMethodS256},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "name", "email", "email_verified"},
+		DPoPSigningAlgValuesSupported:     utils.AsymmetricSigningAlgorithms,
+
+		PushedAuthorizationRequestEndpoint: issuer + "/oauth/par",
+		RequestParameterSupported:          true,
+		// request_uri values are only accepted from the PAR endpoint, never fetched from the client
+		RequestURIParameterSupported:           false,
+		RequestObjectSigningAlgValuesSupported: utils.AsymmetricSigningAlgorithms,
+	})
+}
+
+func (h *DiscoveryHandler) JWKS(c *gin.Context) {
+	jwks, err := utils.PublicJWKS(utils.CurrentKeySet())
+	if err != nil {
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return
+	}
+
+	// Keep the cache short so verifiers pick up new keys soon after they are added
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwks)
+}
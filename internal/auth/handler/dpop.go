@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/usecase"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+// dpopKeyThumbprint verifies the DPoP proof of the request, if it has one, and returns the
+// thumbprint of the key the issued tokens must be bound to.
+func dpopKeyThumbprint(c *gin.Context, verify func(proof, method, uri string) (string, error)) (string, error) {
+	proofs := c.Request.Header.Values("DPoP")
+	if len(proofs) == 0 {
+		return "", nil
+	}
+	if len(proofs) > 1 {
+		return "", fmt.Errorf("%w: only one DPoP header is allowed", utils.ErrInvalidDPoPProof)
+	}
+	return verify(proofs[0], c.Request.Method, usecase.DPoPURI(c.Request.URL.Path))
+}
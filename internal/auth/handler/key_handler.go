@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/usecase"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+type KeyHandler struct {
+	keyManager usecase.KeyManager
+}
+
+func NewKeyHandler(keyManager usecase.KeyManager) *KeyHandler {
+	return &KeyHandler{keyManager: keyManager}
+}
+
+func (h *KeyHandler) ListKeys(c *gin.Context) {
+	keys, err := h.keyManager.List()
+	if err != nil {
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return
+	}
+
+	utils.SendResponse(c, http.StatusOK, "Signing keys retrieved", keys, false)
+}
+
+func (h *KeyHandler) RotateKey(c *gin.Context) {
+	key, err := h.keyManager.Rotate()
+	if err != nil {
+		if errors.Is(err, repository.ErrRotationInProgress) {
+			utils.SendResponse(c, http.StatusConflict, err.Error(), nil, true)
+			return
+		}
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return
+	}
+
+	utils.SendResponse(c, http.StatusOK, "Signing key rotation scheduled", key, false)
+}
+
+func (h *KeyHandler) RevokeKey(c *gin.Context) {
+	if err := h.keyManager.Revoke(c.Param("kid")); err != nil {
+		if errors.Is(err, repository.ErrSigningKeyNotFound) {
+			utils.SendResponse(c, http.StatusNotFound, err.Error(), nil, true)
+			return
+		}
+		utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+		return
+	}
+
+	utils.SendResponse(c, http.StatusOK, "Signing key revoked", nil, false)
+}
@@ -0,0 +1,414 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/usecase"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+type OAuthHandler struct {
+	oauthUseCase usecase.OAuthUseCase
+}
+
+func NewOAuthHandler(oauthUseCase usecase.OAuthUseCase) *OAuthHandler {
+	return &OAuthHandler{oauthUseCase: oauthUseCase}
+}
+
+// Authorize runs for a browser logged in with a session cookie or, for API clients, an access
+// token. Browsers without a session are sent to the login page, which returns them here.
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	var req dto.AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.SendOAuthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 && req.Prompt != usecase.PromptNone && usecase.LoginURI() != "" {
+		// The request is resolved only after the login, so a pushed request_uri is still unused
+		redirectWithParams(c, usecase.LoginURI(), url.Values{"return_to": {utils.Issuer() + c.Request.URL.RequestURI()}})
+		return
+	}
+
+	// Use the parameters pushed to /oauth/par or signed in a request object instead of the query
+	req, err := h.oauthUseCase.ResolveAuthorizeRequest(req)
+	if err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	// Never redirect to a URI that is not registered for the client
+	client, err := h.oauthUseCase.ValidateClientRedirect(req.ClientID, req.RedirectURI)
+	if err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	result, err := h.oauthUseCase.Authorize(userID, c.GetInt64("authTime"), client, req)
+	if err != nil {
+		redirectWithError(c, req.RedirectURI, req.State, err)
+		return
+	}
+
+	if result.ConsentID != "" {
+		redirectWithParams(c, usecase.ConsentURI(), url.Values{"consent_id": {result.ConsentID}})
+		return
+	}
+
+	params := url.Values{"code": {result.Code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	redirectWithParams(c, req.RedirectURI, params)
+}
+
+// PushAuthorizationRequest implements the pushed authorization request endpoint (RFC 9126).
+func (h *OAuthHandler) PushAuthorizationRequest(c *gin.Context) {
+	var req dto.AuthorizeRequest
+	if err := c.ShouldBindWith(&req, binding.FormPost); err != nil {
+		utils.SendOAuthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.oauthUseCase.PushAuthorizationRequest(client, req)
+	if err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *OAuthHandler) ConsentRequest(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.SendResponse(c, http.StatusUnauthorized, "Unauthorized", nil, true)
+		return
+	}
+
+	consentID := c.Query("consent_id")
+	if consentID == "" {
+		utils.SendResponse(c, http.StatusBadRequest, "consent_id is required", nil, true)
+		return
+	}
+
+	resp, err := h.oauthUseCase.ConsentRequest(userID.(uint), consentID)
+	if err != nil {
+		sendUseCaseError(c, err)
+		return
+	}
+
+	utils.SendResponse(c, http.StatusOK, "Consent request found", resp, false)
+}
+
+// CompleteConsent records the user's decision and returns where the consent page must send the
+// browser: the client's redirect_uri with either a code or an access_denied error.
+func (h *OAuthHandler) CompleteConsent(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.SendResponse(c, http.StatusUnauthorized, "Unauthorized", nil, true)
+		return
+	}
+
+	var req dto.ConsentDecisionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		utils.SendResponse(c, http.StatusBadRequest, err.Error(), nil, true)
+		return
+	}
+
+	consentRequest, code, err := h.oauthUseCase.CompleteConsent(userID.(uint), req.ConsentID, req.Approve)
+	if consentRequest == nil {
+		sendUseCaseError(c, err)
+		return
+	}
+
+	params := url.Values{}
+	if err != nil {
+		params = errorParams(err)
+	} else {
+		params.Set("code", code)
+	}
+	if consentRequest.State != "" {
+		params.Set("state", consentRequest.State)
+	}
+
+	redirectURI, urlErr := buildRedirectURI(consentRequest.Authorization.RedirectURI, params)
+	if urlErr != nil {
+		utils.SendResponse(c, http.StatusBadRequest, "redirect_uri is invalid", nil, true)
+		return
+	}
+
+	message := "Authorization denied"
+	if err == nil {
+		message = "Authorization approved"
+	}
+	utils.SendResponse(c, http.StatusOK, message, dto.ConsentDecisionResponse{RedirectURI: redirectURI}, false)
+}
+
+func (h *OAuthHandler) Token(c *gin.Context) {
+	var req dto.TokenRequest
+	if err := c.ShouldBindWith(&req, binding.Form); err != nil {
+		utils.SendOAuthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	// Tokens are bound to the key of the DPoP proof when the client sends one (RFC 9449)
+	jkt, err := dpopKeyThumbprint(c, h.oauthUseCase.VerifyDPoPProof)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidDPoPProof) {
+			utils.SendOAuthError(c, http.StatusBadRequest, "invalid_dpop_proof", err.Error())
+			return
+		}
+		sendOAuthError(c, err)
+		return
+	}
+	req.DPoPKeyThumbprint = jkt
+
+	resp, err := h.oauthUseCase.Token(client, req)
+	if err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *OAuthHandler) UserInfo(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.SendResponse(c, http.StatusUnauthorized, "Unauthorized", nil, true)
+		return
+	}
+
+	claims, err := h.oauthUseCase.UserInfo(userID.(uint), c.GetStringSlice("scopes"))
+	if err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, claims)
+}
+
+func (h *OAuthHandler) DeviceAuthorization(c *gin.Context) {
+	var req dto.DeviceAuthorizationRequest
+	if err := c.ShouldBindWith(&req, binding.Form); err != nil {
+		utils.SendOAuthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.oauthUseCase.DeviceAuthorization(client, req)
+	if err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *OAuthHandler) DeviceVerification(c *gin.Context) {
+	userCode := c.Query("user_code")
+	if userCode == "" {
+		utils.SendResponse(c, http.StatusBadRequest, "user_code is required", nil, true)
+		return
+	}
+
+	resp, err := h.oauthUseCase.DeviceVerification(userCode)
+	if err != nil {
+		sendUseCaseError(c, err)
+		return
+	}
+
+	utils.SendResponse(c, http.StatusOK, "Device authorization request found", resp, false)
+}
+
+func (h *OAuthHandler) CompleteDeviceVerification(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.SendResponse(c, http.StatusUnauthorized, "Unauthorized", nil, true)
+		return
+	}
+
+	var req dto.DeviceVerificationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		utils.SendResponse(c, http.StatusBadRequest, err.Error(), nil, true)
+		return
+	}
+
+	if err := h.oauthUseCase.CompleteDeviceVerification(userID.(uint), req.UserCode, req.Approve); err != nil {
+		sendUseCaseError(c, err)
+		return
+	}
+
+	message := "Device authorization denied"
+	if req.Approve {
+		message = "Device authorized"
+	}
+	utils.SendResponse(c, http.StatusOK, message, nil, false)
+}
+
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	var req dto.IntrospectionRequest
+	if err := c.ShouldBindWith(&req, binding.Form); err != nil {
+		utils.SendOAuthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.oauthUseCase.Introspect(client, req)
+	if err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *OAuthHandler) Revoke(c *gin.Context) {
+	var req dto.RevocationRequest
+	if err := c.ShouldBindWith(&req, binding.Form); err != nil {
+		utils.SendOAuthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	if err := h.oauthUseCase.Revoke(client, req); err != nil {
+		sendOAuthError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// authenticateClient authenticates the calling client and writes the error response when it fails.
+func (h *OAuthHandler) authenticateClient(c *gin.Context) (*entity.OAuthClient, bool) {
+	clientID, clientSecret, authMethod := clientCredentials(c)
+	client, err := h.oauthUseCase.AuthenticateClient(clientID, clientSecret, authMethod)
+	if err != nil {
+		if authMethod == usecase.AuthMethodClientSecretBasic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		sendOAuthError(c, err)
+		return nil, false
+	}
+	return client, true
+}
+
+// clientCredentials reads client_secret_basic credentials, falling back to client_secret_post,
+// and returns the authentication method that was used.
+func clientCredentials(c *gin.Context) (string, string, string) {
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		// RFC 6749 section 2.3.1: credentials are form-urlencoded before being base64 encoded
+		if id, err := url.QueryUnescape(clientID); err == nil {
+			clientID = id
+		}
+		if secret, err := url.QueryUnescape(clientSecret); err == nil {
+			clientSecret = secret
+		}
+		return clientID, clientSecret, usecase.AuthMethodClientSecretBasic
+	}
+	if clientSecret := c.PostForm("client_secret"); clientSecret != "" {
+		return c.PostForm("client_id"), clientSecret, usecase.AuthMethodClientSecretPost
+	}
+	return c.PostForm("client_id"), "", usecase.AuthMethodNone
+}
+
+func sendOAuthError(c *gin.Context, err error) {
+	var oauthErr *usecase.OAuthError
+	if errors.As(err, &oauthErr) {
+		utils.SendOAuthError(c, oauthErr.Status, oauthErr.Code, oauthErr.Description)
+		return
+	}
+	utils.SendOAuthError(c, http.StatusInternalServerError, "server_error", "internal server error")
+}
+
+// sendUseCaseError reports an OAuthError through the standard API response used by first-party endpoints.
+func sendUseCaseError(c *gin.Context, err error) {
+	var oauthErr *usecase.OAuthError
+	if errors.As(err, &oauthErr) {
+		utils.SendResponse(c, oauthErr.Status, oauthErr.Description, nil, true)
+		return
+	}
+	utils.SendResponse(c, http.StatusInternalServerError, err.Error(), nil, true)
+}
+
+func redirectWithError(c *gin.Context, redirectURI, state string, err error) {
+	params := errorParams(err)
+	if state != "" {
+		params.Set("state", state)
+	}
+	redirectWithParams(c, redirectURI, params)
+}
+
+func errorParams(err error) url.Values {
+	params := url.Values{}
+	var oauthErr *usecase.OAuthError
+	if errors.As(err, &oauthErr) {
+		params.Set("error", oauthErr.Code)
+		params.Set("error_description", oauthErr.Description)
+	} else {
+		params.Set("error", "server_error")
+	}
+	return params
+}
+
+func redirectWithParams(c *gin.Context, redirectURI string, params url.Values) {
+	target, err := buildRedirectURI(redirectURI, params)
+	if err != nil {
+		utils.SendOAuthError(c, http.StatusBadRequest, "invalid_request", "redirect_uri is invalid")
+		return
+	}
+
+	c.Redirect(http.StatusFound, target)
+}
+
+// buildRedirectURI adds params to the query of redirectURI, keeping the query it already has.
+func buildRedirectURI(redirectURI string, params url.Values) (string, error) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	target.RawQuery = query.Encode()
+
+	return target.String(), nil
+}
@@ -0,0 +1,139 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	githubBaseURL = "https://github.com"
+	githubAPIURL  = "https://api.github.com"
+)
+
+// GitHub logs users in with their GitHub account. GitHub is plain OAuth 2.0, so the identity
+// comes from its REST API: the profile for the numeric user ID and name, and the emails API
+// for the primary verified address. BaseURL and APIURL point it at GitHub Enterprise Server.
+type GitHub struct {
+	name        string
+	apiURL      string
+	oauthConfig *oauth2.Config
+}
+
+type githubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func NewGitHub(cfg Config) *GitHub {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	apiURL := strings.TrimSuffix(cfg.APIURL, "/")
+	switch {
+	case baseURL == "" || baseURL == githubBaseURL:
+		baseURL = githubBaseURL
+		if apiURL == "" {
+			apiURL = githubAPIURL
+		}
+	case apiURL == "":
+		// GitHub Enterprise Server serves the API below the web URL
+		apiURL = baseURL + "/api/v3"
+	}
+
+	endpoint := oauth2.Endpoint{
+		AuthURL:  baseURL + "/login/oauth/authorize",
+		TokenURL: baseURL + "/login/oauth/access_token",
+	}
+	return &GitHub{
+		name:        cfg.Name,
+		apiURL:      apiURL,
+		oauthConfig: cfg.oauth2Config(endpoint, []string{"read:user", "user:email"}),
+	}
+}
+
+func (p *GitHub) Name() string {
+	return p.name
+}
+
+// AuthCodeURL ignores nonce, since GitHub issues no ID token.
+func (p *GitHub) AuthCodeURL(state, nonce, codeVerifier string) (string, error) {
+	return p.oauthConfig.AuthCodeURL(state, oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+func (p *GitHub) Exchange(ctx context.Context, code, codeVerifier string) (*oauth2.Token, error) {
+	return p.oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+}
+
+func (p *GitHub) Identity(ctx context.Context, token *oauth2.Token, nonce string) (*Identity, error) {
+	client := p.oauthConfig.Client(ctx, token)
+
+	var user githubUser
+	if err := p.get(client, "/user", &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("GitHub user has no id")
+	}
+
+	var emails []githubEmail
+	if err := p.get(client, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+	email := ""
+	for _, candidate := range emails {
+		if candidate.Primary && candidate.Verified {
+			email = candidate.Email
+			break
+		}
+	}
+	if email == "" {
+		return nil, errors.New("GitHub account has no verified primary email")
+	}
+
+	name := user.Name
+	if name == "" {
+		name = user.Login
+	}
+
+	return &Identity{
+		ProviderID:    strconv.FormatInt(user.ID, 10),
+		Email:         email,
+		EmailVerified: true,
+		Name:          name,
+	}, nil
+}
+
+func (p *GitHub) get(client *http.Client, path string, value interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, p.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get GitHub %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get GitHub %s: status code %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(value); err != nil {
+		return fmt.Errorf("failed to decode GitHub %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// newTestGitHub returns a provider whose API is served by handlers, keyed by path.
+func newTestGitHub(t *testing.T, handlers map[string]http.HandlerFunc) *GitHub {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	for path, handler := range handlers {
+		handler := handler
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer access-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			handler(w, r)
+		})
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return NewGitHub(Config{Name: "github", BaseURL: server.URL, APIURL: server.URL, ClientID: testClientID})
+}
+
+func githubUserHandler(user map[string]interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, user)
+	}
+}
+
+func githubEmailsHandler(emails ...githubEmail) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, emails)
+	}
+}
+
+func githubIdentity(p *GitHub) (*Identity, error) {
+	return p.Identity(context.Background(), &oauth2.Token{AccessToken: "access-token", TokenType: "Bearer"}, "")
+}
+
+func TestGitHubIdentityPicksPrimaryVerifiedEmail(t *testing.T) {
+	p := newTestGitHub(t, map[string]http.HandlerFunc{
+		"/user": githubUserHandler(map[string]interface{}{"id": 1, "login": "octocat", "name": "The Octocat"}),
+		"/user/emails": githubEmailsHandler(
+			githubEmail{Email: "work@example.com", Primary: false, Verified: true},
+			githubEmail{Email: "octocat@example.com", Primary: true, Verified: true},
+			githubEmail{Email: "old@example.com", Primary: false, Verified: false},
+		),
+	})
+
+	identity, err := githubIdentity(p)
+	if err != nil {
+		t.Fatalf("Identity() = %v", err)
+	}
+	if identity.Email != "octocat@example.com" || !identity.EmailVerified {
+		t.Errorf("Identity() email = %q (verified %t), want the primary verified octocat@example.com", identity.Email, identity.EmailVerified)
+	}
+	if identity.Name != "The Octocat" {
+		t.Errorf("Identity() name = %q, want %q", identity.Name, "The Octocat")
+	}
+}
+
+func TestGitHubIdentityRejectsUnverifiedEmail(t *testing.T) {
+	p := newTestGitHub(t, map[string]http.HandlerFunc{
+		"/user": githubUserHandler(map[string]interface{}{"id": 1, "login": "octocat"}),
+		"/user/emails": githubEmailsHandler(
+			githubEmail{Email: "octocat@example.com", Primary: true, Verified: false},
+			githubEmail{Email: "work@example.com", Primary: false, Verified: false},
+		),
+	})
+
+	identity, err := githubIdentity(p)
+	if err == nil || !strings.Contains(err.Error(), "no verified primary email") {
+		t.Errorf("Identity() = %+v, %v, want an unverified email error", identity, err)
+	}
+}
+
+func TestGitHubIdentityMapsNumericID(t *testing.T) {
+	p := newTestGitHub(t, map[string]http.HandlerFunc{
+		// Larger than 2^53, so it would lose precision as a float64
+		"/user":        githubUserHandler(map[string]interface{}{"id": int64(9007199254740993), "login": "octocat"}),
+		"/user/emails": githubEmailsHandler(githubEmail{Email: "octocat@example.com", Primary: true, Verified: true}),
+	})
+
+	identity, err := githubIdentity(p)
+	if err != nil {
+		t.Fatalf("Identity() = %v", err)
+	}
+	if identity.ProviderID != "9007199254740993" {
+		t.Errorf("Identity() ProviderID = %q, want %q", identity.ProviderID, "9007199254740993")
+	}
+	if identity.Name != "octocat" {
+		t.Errorf("Identity() name = %q, want the login when the name is empty", identity.Name)
+	}
+}
+
+func TestGitHubIdentityRejectsErrorResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		handlers map[string]http.HandlerFunc
+		wantErr  string
+	}{
+		{
+			name: "user",
+			handlers: map[string]http.HandlerFunc{
+				"/user": func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+				},
+			},
+			wantErr: "failed to get GitHub /user: status code 401",
+		},
+		{
+			name: "emails",
+			handlers: map[string]http.HandlerFunc{
+				"/user": githubUserHandler(map[string]interface{}{"id": 1, "login": "octocat"}),
+				"/user/emails": func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, `{"message":"Resource not accessible by integration"}`, http.StatusForbidden)
+				},
+			},
+			wantErr: "failed to get GitHub /user/emails: status code 403",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := githubIdentity(newTestGitHub(t, tt.handlers))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Identity() = %+v, %v, want error containing %q", identity, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+	"golang.org/x/oauth2"
+)
+
+const googleIssuer = "https://accounts.google.com"
+
+// OIDC logs users in with any OpenID Connect provider, e.g. Google, Keycloak, Okta or Azure AD.
+// Endpoints and signing keys come from the issuer's discovery document, and the user's identity
+// is read from the verified ID token.
+type OIDC struct {
+	name   string
+	issuer string
+	config Config
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+}
+
+type oidcDiscovery struct {
+	oauthConfig *oauth2.Config
+	verifier    *oidc.IDTokenVerifier
+}
+
+type oidcClaims struct {
+	Email           string    `json:"email"`
+	EmailVerified   claimBool `json:"email_verified"`
+	Name            string    `json:"name"`
+	AuthorizedParty string    `json:"azp"`
+}
+
+// NewOIDC configures the provider without contacting it; discovery happens on first use so an
+// unreachable provider does not keep the server from starting.
+func NewOIDC(cfg Config) (*OIDC, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("issuer is not set")
+	}
+	return &OIDC{name: cfg.Name, issuer: cfg.Issuer, config: cfg}, nil
+}
+
+// NewGoogle is an OIDC provider for Google accounts.
+func NewGoogle(cfg Config) (*OIDC, error) {
+	if cfg.Issuer == "" {
+		cfg.Issuer = googleIssuer
+	}
+	return NewOIDC(cfg)
+}
+
+func (p *OIDC) Name() string {
+	return p.name
+}
+
+func (p *OIDC) AuthCodeURL(state, nonce, codeVerifier string) (string, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	return discovery.oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+func (p *OIDC) Exchange(ctx context.Context, code, codeVerifier string) (*oauth2.Token, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	return discovery.oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+}
+
+// Identity verifies the ID token of the token response: its signature against the issuer's
+// keys, iss, aud, exp, the nonce of the login and, when present, azp and at_hash.
+func (p *OIDC) Identity(ctx context.Context, token *oauth2.Token, nonce string) (*Identity, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response has no id_token")
+	}
+	idToken, err := discovery.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify ID token: %w", err)
+	}
+	if nonce == "" || idToken.Nonce != nonce {
+		return nil, errors.New("ID token nonce does not match the login")
+	}
+	if idToken.AccessTokenHash != "" {
+		if err := idToken.VerifyAccessToken(token.AccessToken); err != nil {
+			return nil, fmt.Errorf("failed to verify ID token: %w", err)
+		}
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode ID token claims: %w", err)
+	}
+	// With several audiences the token must have been issued to us (OIDC Core section 3.1.3.7)
+	if len(idToken.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return nil, errors.New("ID token was issued to another client")
+	}
+	if claims.Email == "" {
+		return nil, errors.New("ID token has no email claim")
+	}
+
+	return &Identity{
+		ProviderID:    idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// discover fetches the discovery document once, retrying on later calls if it failed.
+func (p *OIDC) discover() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	// The key set keeps this context to refresh keys, so it must outlive any request
+	ctx := oidc.ClientContext(context.Background(), &http.Client{Timeout: 10 * time.Second})
+	provider, err := oidc.NewProvider(ctx, p.issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OpenID provider %s: %w", p.issuer, err)
+	}
+
+	p.discovery = &oidcDiscovery{
+		oauthConfig: p.config.oauth2Config(provider.Endpoint(), []string{oidc.ScopeOpenID, "profile", "email"}),
+		verifier:    provider.Verifier(&oidc.Config{ClientID: p.config.ClientID}),
+	}
+	return p.discovery, nil
+}
+
+// claimBool accepts booleans sent as JSON strings, as some providers do for email_verified.
+type claimBool bool
+
+func (b *claimBool) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case bool:
+		*b = claimBool(v)
+	case string:
+		*b = claimBool(v == "true")
+	default:
+		*b = false
+	}
+	return nil
+}
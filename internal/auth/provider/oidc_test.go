@@ -0,0 +1,226 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	testClientID = "test-client"
+	testNonce    = "test-nonce"
+	testKeyID    = "test-key"
+)
+
+// testIdP is an OpenID provider serving discovery, its JWKS and a token endpoint that answers
+// every code with the ID token returned by idToken.
+type testIdP struct {
+	server  *httptest.Server
+	key     *rsa.PrivateKey
+	idToken func(accessToken string) string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testKeyID,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		accessToken := "access-token"
+		writeJSON(w, map[string]interface{}{
+			"access_token": accessToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idp.idToken(accessToken),
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+// claims returns the claims of a valid ID token for the access token.
+func (idp *testIdP) claims(accessToken string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"sub":            "12345",
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          testNonce,
+		"at_hash":        accessTokenHash(accessToken),
+		"email":          "user@example.com",
+		"email_verified": true,
+		"name":           "Test User",
+	}
+}
+
+func (idp *testIdP) sign(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// login runs the token exchange and identity check of a callback against the provider.
+func (idp *testIdP) login(t *testing.T) (*Identity, error) {
+	t.Helper()
+
+	p, err := NewOIDC(Config{Name: "test", Issuer: idp.server.URL, ClientID: testClientID, ClientSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := p.Exchange(context.Background(), "code", "verifier")
+	if err != nil {
+		t.Fatalf("Exchange() = %v", err)
+	}
+	return p.Identity(context.Background(), token, testNonce)
+}
+
+func TestOIDCIdentity(t *testing.T) {
+	idp := newTestIdP(t)
+	idp.idToken = func(accessToken string) string {
+		return idp.sign(t, idp.key, idp.claims(accessToken))
+	}
+
+	identity, err := idp.login(t)
+	if err != nil {
+		t.Fatalf("Identity() = %v", err)
+	}
+	want := Identity{ProviderID: "12345", Email: "user@example.com", EmailVerified: true, Name: "Test User"}
+	if *identity != want {
+		t.Errorf("Identity() = %+v, want %+v", *identity, want)
+	}
+}
+
+func TestOIDCIdentityRejects(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     *rsa.PrivateKey
+		modify  func(claims jwt.MapClaims)
+		wantErr string
+	}{
+		{
+			name:    "bad signature",
+			key:     otherKey,
+			modify:  func(claims jwt.MapClaims) {},
+			wantErr: "signature",
+		},
+		{
+			name:    "wrong issuer",
+			modify:  func(claims jwt.MapClaims) { claims["iss"] = "https://attacker.example.com" },
+			wantErr: "different provider",
+		},
+		{
+			name:    "wrong audience",
+			modify:  func(claims jwt.MapClaims) { claims["aud"] = "other-client" },
+			wantErr: "expected audience",
+		},
+		{
+			name: "wrong authorized party",
+			modify: func(claims jwt.MapClaims) {
+				claims["aud"] = []string{testClientID, "other-client"}
+				claims["azp"] = "other-client"
+			},
+			wantErr: "issued to another client",
+		},
+		{
+			name:    "nonce mismatch",
+			modify:  func(claims jwt.MapClaims) { claims["nonce"] = "other-nonce" },
+			wantErr: "nonce does not match",
+		},
+		{
+			name: "expired",
+			modify: func(claims jwt.MapClaims) {
+				claims["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			},
+			wantErr: "token is expired",
+		},
+		{
+			name:    "at_hash mismatch",
+			modify:  func(claims jwt.MapClaims) { claims["at_hash"] = accessTokenHash("other-access-token") },
+			wantErr: "access token hash does not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newTestIdP(t)
+			key := tt.key
+			if key == nil {
+				key = idp.key
+			}
+			idp.idToken = func(accessToken string) string {
+				claims := idp.claims(accessToken)
+				tt.modify(claims)
+				return idp.sign(t, key, claims)
+			}
+
+			identity, err := idp.login(t)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Identity() = %+v, %v, want error containing %q", identity, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// accessTokenHash is the at_hash of an access token for RS256 (OIDC Core section 3.1.3.6).
+func accessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"context"
+
+	"golang.org/x/oauth2"
+)
+
+// Provider is an upstream identity provider users can log in with, such as Google.
+type Provider interface {
+	// Name identifies the provider in the login routes and in users.provider.
+	Name() string
+	// AuthCodeURL returns the provider's login page for the login identified by state. The
+	// provider puts nonce in the ID token it issues for the login, and binds the authorization
+	// code to codeVerifier with PKCE (S256).
+	AuthCodeURL(state, nonce, codeVerifier string) (string, error)
+	// Exchange trades the authorization code of the callback for the provider's tokens.
+	Exchange(ctx context.Context, code, codeVerifier string) (*oauth2.Token, error)
+	// Identity returns the user the tokens were issued for, checking they belong to the login
+	// started with nonce.
+	Identity(ctx context.Context, token *oauth2.Token, nonce string) (*Identity, error)
+}
+
+// Identity is a user as described by a provider, normalized across providers.
+type Identity struct {
+	// ProviderID is the provider's stable identifier for the user.
+	ProviderID    string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Config holds the settings shared by every provider type.
+type Config struct {
+	Name         string
+	Type         string
+	Issuer       string
+	BaseURL      string
+	APIURL       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+func (c Config) oauth2Config(endpoint oauth2.Endpoint, defaultScopes []string) *oauth2.Config {
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+	return &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		RedirectURL:  c.RedirectURL,
+		Scopes:       scopes,
+		Endpoint:     endpoint,
+	}
+}
@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
+)
+
+const (
+	TypeGoogle = "google"
+	TypeOIDC   = "oidc"
+	TypeGitHub = "github"
+)
+
+// Registry holds the providers users can log in with, by name.
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	registry := &Registry{providers: make(map[string]Provider, len(providers))}
+	for _, p := range providers {
+		registry.providers[p.Name()] = p
+	}
+	return registry
+}
+
+// NewRegistryFromConfig builds the providers listed in AUTH_PROVIDERS, e.g. "google,corp".
+// Each one reads <NAME>_TYPE (defaulting to its name), <NAME>_CLIENT_ID, <NAME>_CLIENT_SECRET,
+// <NAME>_REDIRECT_URL and <NAME>_SCOPES, plus <NAME>_ISSUER for OIDC and <NAME>_BASE_URL and
+// <NAME>_API_URL for GitHub. Without AUTH_PROVIDERS, Google is enabled when
+// GOOGLE_CLIENT_ID is set.
+func NewRegistryFromConfig() (*Registry, error) {
+	names := splitList(config.GetEnv("AUTH_PROVIDERS"))
+	if len(names) == 0 && config.GetEnv("GOOGLE_CLIENT_ID") != "" {
+		names = []string{TypeGoogle}
+	}
+
+	providers := make([]Provider, 0, len(names))
+	for _, name := range names {
+		p, err := newProvider(configFor(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure identity provider %s: %w", name, err)
+		}
+		providers = append(providers, p)
+	}
+	return NewRegistry(providers...), nil
+}
+
+func (r *Registry) Get(name string) (Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// Names lists the configured providers in alphabetical order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newProvider(cfg Config) (Provider, error) {
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("client ID is not set")
+	}
+	switch cfg.Type {
+	case TypeGoogle:
+		return NewGoogle(cfg)
+	case TypeOIDC:
+		return NewOIDC(cfg)
+	case TypeGitHub:
+		return NewGitHub(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown provider type %q", cfg.Type)
+	}
+}
+
+// configFor reads the settings of the named provider, e.g. CORP_CLIENT_ID for "corp".
+func configFor(name string) Config {
+	prefix := strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+	providerType := config.GetEnv(prefix + "TYPE")
+	if providerType == "" {
+		providerType = name
+	}
+	return Config{
+		Name:         name,
+		Type:         providerType,
+		Issuer:       config.GetEnv(prefix + "ISSUER"),
+		BaseURL:      config.GetEnv(prefix + "BASE_URL"),
+		APIURL:       config.GetEnv(prefix + "API_URL"),
+		ClientID:     config.GetEnv(prefix + "CLIENT_ID"),
+		ClientSecret: config.GetEnv(prefix + "CLIENT_SECRET"),
+		RedirectURL:  config.GetEnv(prefix + "REDIRECT_URL"),
+		Scopes:       splitList(config.GetEnv(prefix + "SCOPES")),
+	}
+}
+
+// splitList accepts comma or space separated values.
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
@@ -6,9 +6,15 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/lib/pq"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
 )
 
+// uniqueViolation is the Postgres error code of a unique constraint violation.
+const uniqueViolation = "23505"
+
+var ErrUserNotFound = errors.New("user not found")
+
 type UserRepository struct {
 	db *sql.DB
 }
@@ -19,7 +25,7 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 
 func (r *UserRepository) FindByEmail(email string) (*entity.User, error) {
 	query := `
-		SELECT id, email, password, name, provider, provider_id
+		SELECT id, email, email_verified, password, name, provider, provider_id
 		FROM users
 		WHERE email = $1
 	`
@@ -27,6 +33,7 @@ func (r *UserRepository) FindByEmail(email string) (*entity.User, error) {
 	err := r.db.QueryRowContext(context.Background(), query, email).Scan(
 		&user.ID,
 		&user.Email,
+		&user.EmailVerified,
 		&user.Password,
 		&user.Name,
 		&user.Provider,
@@ -34,7 +41,31 @@ func (r *UserRepository) FindByEmail(email string) (*entity.User, error) {
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	return user, nil
+}
+
+func (r *UserRepository) FindByID(id uint) (*entity.User, error) {
+	query := `
+		SELECT id, email, email_verified, name, provider, provider_id
+		FROM users
+		WHERE id = $1
+	`
+	user := &entity.User{}
+	err := r.db.QueryRowContext(context.Background(), query, id).Scan(
+		&user.ID,
+		&user.Email,
+		&user.EmailVerified,
+		&user.Name,
+		&user.Provider,
+		&user.ProviderID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to find user: %w", err)
 	}
@@ -43,7 +74,7 @@ func (r *UserRepository) FindByEmail(email string) (*entity.User, error) {
 
 func (r *UserRepository) FindByProvider(provider, providerID string) (*entity.User, error) {
 	query := `
-		SELECT id, email, name, provider, provider_id
+		SELECT id, email, email_verified, name, provider, provider_id
 		FROM users
 		WHERE provider = $1 AND provider_id = $2
 	`
@@ -51,13 +82,14 @@ func (r *UserRepository) FindByProvider(provider, providerID string) (*entity.Us
 	err := r.db.QueryRowContext(context.Background(), query, provider, providerID).Scan(
 		&user.ID,
 		&user.Email,
+		&user.EmailVerified,
 		&user.Name,
 		&user.Provider,
 		&user.ProviderID,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to find user: %w", err)
 	}
@@ -66,14 +98,15 @@ func (r *UserRepository) FindByProvider(provider, providerID string) (*entity.Us
 
 func (r *UserRepository) Create(user *entity.User) error {
 	query := `
-		INSERT INTO users (email, password, name, provider, provider_id)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (email, email_verified, password, name, provider, provider_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id
 	`
 	err := r.db.QueryRowContext(
 		context.Background(),
 		query,
 		user.Email,
+		user.EmailVerified,
 		user.Password,
 		user.Name,
 		user.Provider,
@@ -85,40 +118,37 @@ func (r *UserRepository) Create(user *entity.User) error {
 	return nil
 }
 
-func (r *UserRepository) FindOrCreateUserByProvider(provider, email, providerID, name string) (*entity.User, error) {
-	// Check if the user already exists
-	query := `
-		SELECT id, email, name, provider, provider_id
-		FROM users
-		WHERE provider = $1 AND provider_id = $2
-	`
-	user := &entity.User{}
-	err := r.db.QueryRowContext(context.Background(), query, provider, providerID).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Name,
-		&user.Provider,
-		&user.ProviderID,
-	)
+// ErrEmailInUse is returned when a new provider user has the email of an existing account.
+var ErrEmailInUse = errors.New("email is already used by another account")
+
+// FindOrCreateUserByProvider returns the user the provider knows as providerID, creating it on
+// the first login. Provider users have no password, so it is stored empty.
+func (r *UserRepository) FindOrCreateUserByProvider(provider, email, providerID, name string, emailVerified bool) (*entity.User, error) {
+	user, err := r.FindByProvider(provider, providerID)
 	if err == nil {
 		return user, nil
 	}
-
-	// If the user doesn't exist, create a new user
-	query = `
-		INSERT INTO users (email, name, provider, provider_id)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id
-	`
-	err = r.db.QueryRowContext(context.Background(), query, email, name, provider, providerID).Scan(&user.ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+	if !errors.Is(err, ErrUserNotFound) {
+		return nil, err
 	}
 
-	user.Email = email
-	user.Name = name
-	user.Provider = provider
-	user.ProviderID = providerID
-
+	user = &entity.User{
+		Email:         email,
+		EmailVerified: emailVerified,
+		Name:          name,
+		Provider:      provider,
+		ProviderID:    providerID,
+	}
+	if err := r.Create(user); err != nil {
+		var pqErr *pq.Error
+		if !errors.As(err, &pqErr) || pqErr.Code != uniqueViolation {
+			return nil, err
+		}
+		// A concurrent first login of the same user may have created it meanwhile
+		if existing, findErr := r.FindByProvider(provider, providerID); findErr == nil {
+			return existing, nil
+		}
+		return nil, ErrEmailInUse
+	}
 	return user, nil
 }
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+)
+
+var ErrClientNotFound = errors.New("client not found")
+
+type ClientRepository struct {
+	db *sql.DB
+}
+
+func NewClientRepository(db *sql.DB) *ClientRepository {
+	return &ClientRepository{db: db}
+}
+
+func (r *ClientRepository) FindByClientID(clientID string) (*entity.OAuthClient, error) {
+	query := `
+		SELECT id, client_id, client_secret, name, redirect_uris, grant_types, scopes,
+			token_endpoint_auth_method, registration_access_token, token_exchange_audiences,
+			jwks, require_pushed_authorization_requests, created_at
+		FROM oauth_clients
+		WHERE client_id = $1
+	`
+	client := &entity.OAuthClient{}
+	var clientSecret, authMethod, registrationToken, jwks sql.NullString
+	err := r.db.QueryRowContext(context.Background(), query, clientID).Scan(
+		&client.ID,
+		&client.ClientID,
+		&clientSecret,
+		&client.Name,
+		pq.Array(&client.RedirectURIs),
+		pq.Array(&client.GrantTypes),
+		pq.Array(&client.Scopes),
+		&authMethod,
+		&registrationToken,
+		pq.Array(&client.TokenExchangeAudiences),
+		&jwks,
+		&client.RequirePushedAuthorizationRequests,
+		&client.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrClientNotFound
+		}
+		return nil, fmt.Errorf("failed to find client: %w", err)
+	}
+	client.ClientSecret = clientSecret.String
+	client.TokenEndpointAuthMethod = authMethod.String
+	client.RegistrationAccessToken = registrationToken.String
+	client.JWKS = jwks.String
+	return client, nil
+}
+
+func (r *ClientRepository) Create(client *entity.OAuthClient) error {
+	query := `
+		INSERT INTO oauth_clients (client_id, client_secret, name, redirect_uris, grant_types, scopes,
+			token_endpoint_auth_method, registration_access_token, jwks, require_pushed_authorization_requests)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowContext(
+		context.Background(),
+		query,
+		client.ClientID,
+		nullString(client.ClientSecret),
+		client.Name,
+		pq.Array(client.RedirectURIs),
+		pq.Array(client.GrantTypes),
+		pq.Array(client.Scopes),
+		nullString(client.TokenEndpointAuthMethod),
+		nullString(client.RegistrationAccessToken),
+		nullString(client.JWKS),
+		client.RequirePushedAuthorizationRequests,
+	).Scan(&client.ID, &client.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+	return nil
+}
+
+// Update replaces the client metadata. The client_id, secret and registration token are left untouched.
+func (r *ClientRepository) Update(client *entity.OAuthClient) error {
+	query := `
+		UPDATE oauth_clients
+		SET name = $2, redirect_uris = $3, grant_types = $4, scopes = $5, token_endpoint_auth_method = $6,
+			jwks = $7, require_pushed_authorization_requests = $8
+		WHERE client_id = $1
+	`
+	result, err := r.db.ExecContext(
+		context.Background(),
+		query,
+		client.ClientID,
+		client.Name,
+		pq.Array(client.RedirectURIs),
+		pq.Array(client.GrantTypes),
+		pq.Array(client.Scopes),
+		nullString(client.TokenEndpointAuthMethod),
+		nullString(client.JWKS),
+		client.RequirePushedAuthorizationRequests,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update client: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrClientNotFound
+	}
+	return nil
+}
+
+func (r *ClientRepository) Delete(clientID string) error {
+	result, err := r.db.ExecContext(context.Background(), `DELETE FROM oauth_clients WHERE client_id = $1`, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to delete client: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrClientNotFound
+	}
+	return nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
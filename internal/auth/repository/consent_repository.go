@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+)
+
+var ErrConsentNotFound = errors.New("consent not found")
+
+type ConsentRepository struct {
+	db *sql.DB
+}
+
+func NewConsentRepository(db *sql.DB) *ConsentRepository {
+	return &ConsentRepository{db: db}
+}
+
+func (r *ConsentRepository) Find(userID uint, clientID string) (*entity.Consent, error) {
+	query := `
+		SELECT user_id, client_id, scopes, created_at, updated_at
+		FROM oauth_consents
+		WHERE user_id = $1 AND client_id = $2
+	`
+	consent := &entity.Consent{}
+	err := r.db.QueryRowContext(context.Background(), query, userID, clientID).Scan(
+		&consent.UserID,
+		&consent.ClientID,
+		pq.Array(&consent.Scopes),
+		&consent.CreatedAt,
+		&consent.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrConsentNotFound
+		}
+		return nil, fmt.Errorf("failed to find consent: %w", err)
+	}
+	return consent, nil
+}
+
+// Save creates the consent or replaces the scopes of an existing one.
+func (r *ConsentRepository) Save(consent *entity.Consent) error {
+	query := `
+		INSERT INTO oauth_consents (user_id, client_id, scopes)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, client_id) DO UPDATE SET scopes = EXCLUDED.scopes, updated_at = NOW()
+	`
+	_, err := r.db.ExecContext(context.Background(), query, consent.UserID, consent.ClientID, pq.Array(consent.Scopes))
+	if err != nil {
+		return fmt.Errorf("failed to save consent: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+)
+
+// keyRotationLockID serializes rotations across server instances with a Postgres advisory lock.
+const keyRotationLockID = 7263401
+
+var (
+	ErrRotationInProgress = errors.New("key rotation already in progress")
+	ErrSigningKeyNotFound = errors.New("signing key not found")
+)
+
+type KeyRepository struct {
+	db *sql.DB
+}
+
+func NewKeyRepository(db *sql.DB) *KeyRepository {
+	return &KeyRepository{db: db}
+}
+
+func (r *KeyRepository) FindAll() ([]*entity.SigningKey, error) {
+	query := `
+		SELECT id, kid, algorithm, private_key, created_at, activates_at, expires_at, revoked_at
+		FROM signing_keys
+		ORDER BY activates_at DESC
+	`
+	rows, err := r.db.QueryContext(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*entity.SigningKey
+	for rows.Next() {
+		key := &entity.SigningKey{}
+		var expiresAt, revokedAt sql.NullTime
+		if err := rows.Scan(
+			&key.ID,
+			&key.KeyID,
+			&key.Algorithm,
+			&key.PrivateKey,
+			&key.CreatedAt,
+			&key.ActivatesAt,
+			&expiresAt,
+			&revokedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+		if expiresAt.Valid {
+			key.ExpiresAt = &expiresAt.Time
+		}
+		if revokedAt.Valid {
+			key.RevokedAt = &revokedAt.Time
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Rotate inserts the next key and schedules every key without an expiry to stop verifying at retireAt.
+func (r *KeyRepository) Rotate(key *entity.SigningKey, retireAt time.Time) error {
+	ctx := context.Background()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin key rotation: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, keyRotationLockID).Scan(&locked); err != nil {
+		return fmt.Errorf("failed to lock signing keys: %w", err)
+	}
+	if !locked {
+		return ErrRotationInProgress
+	}
+
+	query := `
+		UPDATE signing_keys
+		SET expires_at = $1
+		WHERE expires_at IS NULL AND revoked_at IS NULL
+	`
+	if _, err := tx.ExecContext(ctx, query, retireAt); err != nil {
+		return fmt.Errorf("failed to retire signing keys: %w", err)
+	}
+
+	if err := insertSigningKey(ctx, tx, key); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit key rotation: %w", err)
+	}
+	return nil
+}
+
+// CreateFirst inserts key unless another instance already created a key that can sign at now.
+// It waits for the rotation lock, so instances starting together agree on a single first key.
+func (r *KeyRepository) CreateFirst(key *entity.SigningKey, now time.Time) (bool, error) {
+	ctx := context.Background()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin key creation: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, keyRotationLockID); err != nil {
+		return false, fmt.Errorf("failed to lock signing keys: %w", err)
+	}
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM signing_keys
+			WHERE revoked_at IS NULL AND activates_at <= $1 AND (expires_at IS NULL OR expires_at > $1)
+		)
+	`
+	var exists bool
+	if err := tx.QueryRowContext(ctx, query, now).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to find signing keys: %w", err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	if err := insertSigningKey(ctx, tx, key); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit key creation: %w", err)
+	}
+	return true, nil
+}
+
+func (r *KeyRepository) Revoke(kid string, revokedAt time.Time) error {
+	query := `
+		UPDATE signing_keys
+		SET revoked_at = $2
+		WHERE kid = $1 AND revoked_at IS NULL
+	`
+	result, err := r.db.ExecContext(context.Background(), query, kid, revokedAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke signing key: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrSigningKeyNotFound
+	}
+	return nil
+}
+
+// DeleteExpired removes key material that can no longer verify any token.
+func (r *KeyRepository) DeleteExpired(before time.Time) error {
+	query := `
+		DELETE FROM signing_keys
+		WHERE expires_at < $1 OR revoked_at < $1
+	`
+	if _, err := r.db.ExecContext(context.Background(), query, before); err != nil {
+		return fmt.Errorf("failed to delete expired signing keys: %w", err)
+	}
+	return nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func insertSigningKey(ctx context.Context, db queryRower, key *entity.SigningKey) error {
+	query := `
+		INSERT INTO signing_keys (kid, algorithm, private_key, activates_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	err := db.QueryRowContext(ctx, query, key.KeyID, key.Algorithm, key.PrivateKey, key.ActivatesAt).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create signing key: %w", err)
+	}
+	return nil
+}
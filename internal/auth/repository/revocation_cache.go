@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	revocationChannel = "token_revocations"
+	revokedKeyPrefix  = "revoked:"
+
+	revocationPruneInterval = time.Minute
+)
+
+// ErrRevocationCacheStale is returned while the cache may have missed revocations,
+// e.g. before the first snapshot or after the pub/sub connection dropped.
+var ErrRevocationCacheStale = errors.New("revocation cache is not in sync")
+
+// revocationEvent is published whenever a revocation list entry is written.
+type revocationEvent struct {
+	Key       string `json:"key"`
+	Value     int64  `json:"value"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+type revocationEntry struct {
+	value     int64
+	expiresAt int64
+}
+
+// RevocationCache keeps the revocation list in memory so AuthMiddleware does not query
+// Redis on every request. It loads a snapshot of the revoked:* keys and applies the
+// changes published by TokenRepository, reloading the snapshot after every reconnect.
+type RevocationCache struct {
+	redisClient *redis.Client
+
+	mu      sync.RWMutex
+	entries map[string]revocationEntry
+	synced  bool
+}
+
+func NewRevocationCache(redisClient *redis.Client) *RevocationCache {
+	return &RevocationCache{redisClient: redisClient, entries: map[string]revocationEntry{}}
+}
+
+// Run keeps the cache in sync until ctx is done.
+func (c *RevocationCache) Run(ctx context.Context) {
+	pubsub := c.redisClient.Subscribe(revocationChannel)
+	defer pubsub.Close()
+
+	go func() {
+		<-ctx.Done()
+		pubsub.Close()
+	}()
+
+	lastPrune := time.Now()
+	for ctx.Err() == nil {
+		if time.Since(lastPrune) >= revocationPruneInterval {
+			c.prune(time.Now())
+			lastPrune = time.Now()
+		}
+
+		msg, err := pubsub.ReceiveTimeout(revocationPruneInterval)
+		if err != nil {
+			if isTimeout(err) {
+				continue
+			}
+			if ctx.Err() == nil {
+				c.setSynced(false)
+				log.Printf("Revocation cache lost its subscription: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			// Sent on every (re)subscribe, events published while disconnected are in the snapshot
+			if err := c.load(); err != nil {
+				c.setSynced(false)
+				log.Printf("Failed to load revocation list: %v", err)
+				continue
+			}
+			c.setSynced(true)
+		case *redis.Message:
+			var event revocationEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Printf("Invalid revocation event: %v", err)
+				continue
+			}
+			c.set(event.Key, revocationEntry{value: event.Value, expiresAt: event.ExpiresAt})
+		}
+	}
+}
+
+// IsAccessTokenRevoked mirrors TokenRepository.IsAccessTokenRevoked without a Redis round trip.
+func (c *RevocationCache) IsAccessTokenRevoked(jti, sessionID string, userID uint, issuedAt time.Time) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.synced {
+		return false, ErrRevocationCacheStale
+	}
+
+	now := time.Now().Unix()
+	if c.live(revokedAccessTokenKey(jti), now) {
+		return true, nil
+	}
+	if sessionID != "" && c.live(revokedSessionKey(sessionID), now) {
+		return true, nil
+	}
+	if userID != 0 {
+		if entry, ok := c.entries[revokedUserKey(userID)]; ok && entry.expiresAt > now && issuedAt.Unix() < entry.value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *RevocationCache) live(key string, now int64) bool {
+	entry, ok := c.entries[key]
+	return ok && entry.expiresAt > now
+}
+
+// load replaces the cache with the revocation entries currently stored in Redis.
+func (c *RevocationCache) load() error {
+	entries := map[string]revocationEntry{}
+
+	iter := c.redisClient.Scan(0, revokedKeyPrefix+"*", 1000).Iterator()
+	for iter.Next() {
+		key := iter.Val()
+
+		var get *redis.StringCmd
+		var ttl *redis.DurationCmd
+		_, err := c.redisClient.Pipelined(func(pipe redis.Pipeliner) error {
+			get = pipe.Get(key)
+			ttl = pipe.PTTL(key)
+			return nil
+		})
+		if errors.Is(err, redis.Nil) {
+			// Expired between SCAN and GET
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", key, err)
+		}
+
+		value, err := strconv.ParseInt(get.Val(), 10, 64)
+		if err != nil || ttl.Val() <= 0 {
+			continue
+		}
+		entries[key] = revocationEntry{value: value, expiresAt: time.Now().Add(ttl.Val()).Unix()}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan revocation list: %w", err)
+	}
+
+	// load runs inside the Run loop, so no event is applied while the snapshot is read
+	// and the snapshot replaces the cache as a whole
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = entries
+	return nil
+}
+
+func (c *RevocationCache) set(key string, entry revocationEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+}
+
+func (c *RevocationCache) setSynced(synced bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.synced = synced
+}
+
+func (c *RevocationCache) prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		if entry.expiresAt <= now.Unix() {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
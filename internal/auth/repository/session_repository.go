@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionRepository struct {
+	redisClient *redis.Client
+}
+
+func NewSessionRepository(redisClient *redis.Client) *SessionRepository {
+	return &SessionRepository{redisClient: redisClient}
+}
+
+// Save stores the session and indexes it under its user until it expires.
+func (r *SessionRepository) Save(session *entity.Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	ttl := time.Until(session.ExpiresAt)
+	_, err = r.redisClient.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Set(sessionKey(session.ID), data, ttl)
+		pipe.SAdd(userSessionsKey(session.UserID), session.ID)
+		pipe.Expire(userSessionsKey(session.UserID), ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) Find(id string) (*entity.Session, error) {
+	data, err := r.redisClient.Get(sessionKey(id)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	var session entity.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &session, nil
+}
+
+// FindByUser returns the user's live sessions and drops expired ones from the index.
+func (r *SessionRepository) FindByUser(userID uint) ([]*entity.Session, error) {
+	ids, err := r.redisClient.SMembers(userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := make([]*entity.Session, 0, len(ids))
+	for _, id := range ids {
+		session, err := r.Find(id)
+		if errors.Is(err, ErrSessionNotFound) {
+			r.redisClient.SRem(userSessionsKey(userID), id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (r *SessionRepository) Delete(session *entity.Session) error {
+	_, err := r.redisClient.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Del(sessionKey(session.ID))
+		pipe.SRem(userSessionsKey(session.UserID), session.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// SaveCookie links the secret of a browser session cookie to its session.
+func (r *SessionRepository) SaveCookie(secret, sessionID string, ttl time.Duration) error {
+	if err := r.redisClient.Set(sessionCookieKey(secret), sessionID, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store session cookie: %w", err)
+	}
+	return nil
+}
+
+// FindByCookie returns the session of a browser session cookie. The link outlives a revoked
+// session, so the session itself is looked up every time.
+func (r *SessionRepository) FindByCookie(secret string) (*entity.Session, error) {
+	sessionID, err := r.redisClient.Get(sessionCookieKey(secret)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to read session cookie: %w", err)
+	}
+	return r.Find(sessionID)
+}
+
+func sessionKey(id string) string {
+	return "session:" + id
+}
+
+func userSessionsKey(userID uint) string {
+	return fmt.Sprintf("user:%d:sessions", userID)
+}
+
+func sessionCookieKey(secret string) string {
+	return "session_cookie:" + utils.HashToken(secret)
+}
@@ -0,0 +1,452 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+type TokenRepository struct {
+	redisClient *redis.Client
+}
+
+func NewTokenRepository(redisClient *redis.Client) *TokenRepository {
+	return &TokenRepository{redisClient: redisClient}
+}
+
+func (r *TokenRepository) SaveAuthorizationCode(code string, authCode *entity.AuthorizationCode) error {
+	return r.set(authorizationCodeKey(code), authCode, time.Until(authCode.ExpiresAt))
+}
+
+// ConsumeAuthorizationCode reads and deletes the code in one transaction so it can be redeemed only once.
+func (r *TokenRepository) ConsumeAuthorizationCode(code string) (*entity.AuthorizationCode, error) {
+	var authCode entity.AuthorizationCode
+	if err := r.getAndDelete(authorizationCodeKey(code), &authCode); err != nil {
+		return nil, err
+	}
+	return &authCode, nil
+}
+
+// SaveAccessToken stores the claims of an opaque access token until it expires.
+func (r *TokenRepository) SaveAccessToken(token string, claims map[string]interface{}, expiresAt time.Time) error {
+	return r.set(accessTokenKey(token), claims, time.Until(expiresAt))
+}
+
+func (r *TokenRepository) FindAccessToken(token string) (map[string]interface{}, error) {
+	var claims map[string]interface{}
+	if err := r.get(accessTokenKey(token), &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// DeleteAccessToken revokes an opaque access token immediately.
+func (r *TokenRepository) DeleteAccessToken(token string) error {
+	if err := r.redisClient.Del(accessTokenKey(token)).Err(); err != nil {
+		return fmt.Errorf("failed to delete access token: %w", err)
+	}
+	return nil
+}
+
+// SaveRefreshToken stores the token and keeps its family alive for as long as the token is valid.
+// A new family is created only if it does not exist yet, and an existing one is extended only
+// while it still exists, so a family revoked by reuse detection cannot be brought back.
+func (r *TokenRepository) SaveRefreshToken(token string, refreshToken *entity.RefreshToken, newFamily bool) error {
+	ttl := time.Until(refreshToken.ExpiresAt)
+	familyKey := refreshFamilyKey(refreshToken.FamilyID)
+
+	var stored bool
+	var err error
+	if newFamily {
+		stored, err = r.redisClient.SetNX(familyKey, refreshToken.UserID, ttl).Result()
+	} else {
+		stored, err = r.redisClient.SetXX(familyKey, refreshToken.UserID, ttl).Result()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store refresh token family: %w", err)
+	}
+	if !stored {
+		if newFamily {
+			return errors.New("refresh token family already exists")
+		}
+		return ErrTokenNotFound
+	}
+
+	return r.set(refreshTokenKey(token), refreshToken, ttl)
+}
+
+// FindRefreshToken returns the token while neither its family nor the user's tokens have been revoked. Rotated tokens are
+// still returned, with Rotated set, so that their reuse can be detected.
+func (r *TokenRepository) FindRefreshToken(token string) (*entity.RefreshToken, error) {
+	var refreshToken entity.RefreshToken
+	if err := r.get(refreshTokenKey(token), &refreshToken); err != nil {
+		return nil, err
+	}
+
+	var familyExists, rotated *redis.IntCmd
+	var watermark *redis.StringCmd
+	_, err := r.redisClient.Pipelined(func(pipe redis.Pipeliner) error {
+		familyExists = pipe.Exists(refreshFamilyKey(refreshToken.FamilyID))
+		rotated = pipe.Exists(rotatedRefreshTokenKey(token))
+		watermark = pipe.Get(revokedUserKey(refreshToken.UserID))
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to read refresh token family: %w", err)
+	}
+	if familyExists.Val() == 0 {
+		return nil, ErrTokenNotFound
+	}
+	if before, err := watermark.Int64(); err == nil && refreshToken.IssuedAt.Unix() < before {
+		return nil, ErrTokenNotFound
+	}
+	refreshToken.Rotated = rotated.Val() == 1
+
+	return &refreshToken, nil
+}
+
+// MarkRefreshTokenRotated reports whether this call rotated the token, so two concurrent
+// refreshes with the same token cannot both succeed.
+func (r *TokenRepository) MarkRefreshTokenRotated(token string, refreshToken *entity.RefreshToken) (bool, error) {
+	marked, err := r.redisClient.SetNX(rotatedRefreshTokenKey(token), time.Now().Unix(), time.Until(refreshToken.ExpiresAt)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	return marked, nil
+}
+
+// RevokeRefreshTokenFamily invalidates every refresh token descending from the same grant.
+func (r *TokenRepository) RevokeRefreshTokenFamily(familyID string) error {
+	if err := r.redisClient.Del(refreshFamilyKey(familyID)).Err(); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+// SaveDeviceAuthorization stores the pending device flow together with an index from its user code.
+// Records are kept for a grace period after expiry so polling clients receive expired_token.
+func (r *TokenRepository) SaveDeviceAuthorization(deviceAuth *entity.DeviceAuthorization, grace time.Duration) error {
+	ttl := time.Until(deviceAuth.ExpiresAt) + grace
+	if err := r.set(deviceCodeKey(deviceAuth.DeviceCodeHash), deviceAuth, ttl); err != nil {
+		return err
+	}
+	if deviceAuth.Status != entity.DeviceAuthorizationPending {
+		// A decided request can no longer be looked up by user code
+		return r.redisClient.Del(userCodeKey(deviceAuth.UserCode)).Err()
+	}
+	if err := r.redisClient.Set(userCodeKey(deviceAuth.UserCode), deviceAuth.DeviceCodeHash, time.Until(deviceAuth.ExpiresAt)).Err(); err != nil {
+		return fmt.Errorf("failed to store user code: %w", err)
+	}
+	return nil
+}
+
+func (r *TokenRepository) FindDeviceAuthorization(deviceCode string) (*entity.DeviceAuthorization, error) {
+	var deviceAuth entity.DeviceAuthorization
+	if err := r.get(deviceCodeKey(utils.HashToken(deviceCode)), &deviceAuth); err != nil {
+		return nil, err
+	}
+	return &deviceAuth, nil
+}
+
+func (r *TokenRepository) FindDeviceAuthorizationByUserCode(userCode string) (*entity.DeviceAuthorization, error) {
+	deviceCodeHash, err := r.redisClient.Get(userCodeKey(userCode)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to read user code: %w", err)
+	}
+
+	var deviceAuth entity.DeviceAuthorization
+	if err := r.get(deviceCodeKey(deviceCodeHash), &deviceAuth); err != nil {
+		return nil, err
+	}
+	return &deviceAuth, nil
+}
+
+// SaveDevicePoll stores the polling state of the device flow for as long as the flow itself.
+func (r *TokenRepository) SaveDevicePoll(deviceAuth *entity.DeviceAuthorization, poll *entity.DevicePoll, grace time.Duration) error {
+	return r.set(devicePollKey(deviceAuth.DeviceCodeHash), poll, time.Until(deviceAuth.ExpiresAt)+grace)
+}
+
+func (r *TokenRepository) FindDevicePoll(deviceAuth *entity.DeviceAuthorization) (*entity.DevicePoll, error) {
+	var poll entity.DevicePoll
+	if err := r.get(devicePollKey(deviceAuth.DeviceCodeHash), &poll); err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+// DeleteDeviceAuthorization removes the device flow and reports whether this call deleted it,
+// so concurrent polls cannot both redeem the same approval.
+func (r *TokenRepository) DeleteDeviceAuthorization(deviceAuth *entity.DeviceAuthorization) (bool, error) {
+	deleted, err := r.redisClient.Del(deviceCodeKey(deviceAuth.DeviceCodeHash)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete device authorization: %w", err)
+	}
+	if err := r.redisClient.Del(userCodeKey(deviceAuth.UserCode), devicePollKey(deviceAuth.DeviceCodeHash)).Err(); err != nil {
+		return false, fmt.Errorf("failed to delete user code: %w", err)
+	}
+	return deleted == 1, nil
+}
+
+func (r *TokenRepository) SaveConsentRequest(consentRequest *entity.ConsentRequest) error {
+	return r.set(consentRequestKey(consentRequest.ID), consentRequest, time.Until(consentRequest.ExpiresAt))
+}
+
+func (r *TokenRepository) FindConsentRequest(id string) (*entity.ConsentRequest, error) {
+	var consentRequest entity.ConsentRequest
+	if err := r.get(consentRequestKey(id), &consentRequest); err != nil {
+		return nil, err
+	}
+	return &consentRequest, nil
+}
+
+// ConsumeConsentRequest reads and deletes the request so the user's decision is applied only once.
+func (r *TokenRepository) ConsumeConsentRequest(id string) (*entity.ConsentRequest, error) {
+	var consentRequest entity.ConsentRequest
+	if err := r.getAndDelete(consentRequestKey(id), &consentRequest); err != nil {
+		return nil, err
+	}
+	return &consentRequest, nil
+}
+
+func (r *TokenRepository) SavePushedAuthorizationRequest(requestURI string, request *entity.PushedAuthorizationRequest) error {
+	return r.set(pushedAuthorizationRequestKey(requestURI), request, time.Until(request.ExpiresAt))
+}
+
+// ConsumePushedAuthorizationRequest reads and deletes the request, so a request_uri can be used only once.
+func (r *TokenRepository) ConsumePushedAuthorizationRequest(requestURI string) (*entity.PushedAuthorizationRequest, error) {
+	var request entity.PushedAuthorizationRequest
+	if err := r.getAndDelete(pushedAuthorizationRequestKey(requestURI), &request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *TokenRepository) SaveLoginFlow(state string, flow *entity.LoginFlow) error {
+	return r.set(loginFlowKey(state), flow, time.Until(flow.ExpiresAt))
+}
+
+// ConsumeLoginFlow returns and deletes the login in one step, so a callback can only complete it once.
+func (r *TokenRepository) ConsumeLoginFlow(state string) (*entity.LoginFlow, error) {
+	var flow entity.LoginFlow
+	if err := r.getAndDelete(loginFlowKey(state), &flow); err != nil {
+		return nil, err
+	}
+	return &flow, nil
+}
+
+func (r *TokenRepository) SaveLoginCode(code string, loginCode *entity.LoginCode) error {
+	return r.set(loginCodeKey(code), loginCode, time.Until(loginCode.ExpiresAt))
+}
+
+func (r *TokenRepository) ConsumeLoginCode(code string) (*entity.LoginCode, error) {
+	var loginCode entity.LoginCode
+	if err := r.getAndDelete(loginCodeKey(code), &loginCode); err != nil {
+		return nil, err
+	}
+	return &loginCode, nil
+}
+
+// MarkDPoPProofUsed records a DPoP proof's jti for ttl and reports whether it was seen for the first time.
+func (r *TokenRepository) MarkDPoPProofUsed(thumbprint, jti string, ttl time.Duration) (bool, error) {
+	marked, err := r.redisClient.SetNX(dpopProofKey(thumbprint, jti), 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to record DPoP proof: %w", err)
+	}
+	return marked, nil
+}
+
+// RevokeAccessToken rejects the access token with this jti until it would have expired anyway.
+func (r *TokenRepository) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	if err := r.revoke(revokedAccessTokenKey(jti), 1, time.Until(expiresAt)); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	return nil
+}
+
+// RevokeSessionTokens rejects the access tokens bound to a session. ttl must cover the lifetime
+// of the last access token issued for it.
+func (r *TokenRepository) RevokeSessionTokens(sessionID string, ttl time.Duration) error {
+	if err := r.revoke(revokedSessionKey(sessionID), 1, ttl); err != nil {
+		return fmt.Errorf("failed to revoke session tokens: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserTokensBefore rejects every token of the user issued before t, in a single write.
+// The watermark is kept for ttl, which must cover the lifetime of the longest-lived token.
+func (r *TokenRepository) RevokeUserTokensBefore(userID uint, t time.Time, ttl time.Duration) error {
+	if err := r.revoke(revokedUserKey(userID), t.Unix(), ttl); err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+	return nil
+}
+
+// IsAccessTokenRevoked checks the token's jti, its session and the user's watermark in Redis.
+// sessionID is empty for tokens without a session, and userID zero for client_credentials tokens.
+func (r *TokenRepository) IsAccessTokenRevoked(jti, sessionID string, userID uint, issuedAt time.Time) (bool, error) {
+	keys := []string{revokedAccessTokenKey(jti)}
+	if sessionID != "" {
+		keys = append(keys, revokedSessionKey(sessionID))
+	}
+	if userID != 0 {
+		keys = append(keys, revokedUserKey(userID))
+	}
+
+	values, err := r.redisClient.MGet(keys...).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		if keys[i] != revokedUserKey(userID) {
+			return true, nil
+		}
+		if before, err := strconv.ParseInt(value.(string), 10, 64); err == nil && issuedAt.Unix() < before {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// revoke stores a revocation list entry and announces it to the in-process caches of every instance.
+func (r *TokenRepository) revoke(key string, value int64, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	event, err := json.Marshal(revocationEvent{Key: key, Value: value, ExpiresAt: time.Now().Add(ttl).Unix()})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.redisClient.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Set(key, value, ttl)
+		pipe.Publish(revocationChannel, event)
+		return nil
+	})
+	return err
+}
+
+func (r *TokenRepository) set(key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	if err := r.redisClient.Set(key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return nil
+}
+
+func (r *TokenRepository) get(key string, value interface{}) error {
+	data, err := r.redisClient.Get(key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrTokenNotFound
+		}
+		return fmt.Errorf("failed to read token: %w", err)
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("failed to decode token: %w", err)
+	}
+	return nil
+}
+
+func (r *TokenRepository) getAndDelete(key string, value interface{}) error {
+	var get *redis.StringCmd
+	_, err := r.redisClient.TxPipelined(func(pipe redis.Pipeliner) error {
+		get = pipe.Get(key)
+		pipe.Del(key)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrTokenNotFound
+		}
+		return fmt.Errorf("failed to read token: %w", err)
+	}
+	if err := json.Unmarshal([]byte(get.Val()), value); err != nil {
+		return fmt.Errorf("failed to decode token: %w", err)
+	}
+	return nil
+}
+
+// Tokens are stored under their hash so a Redis dump does not leak usable credentials.
+func authorizationCodeKey(code string) string {
+	return "oauth:code:" + utils.HashToken(code)
+}
+
+func accessTokenKey(token string) string {
+	return "oauth:access_token:" + utils.HashToken(token)
+}
+
+func refreshTokenKey(token string) string {
+	return "oauth:refresh_token:" + utils.HashToken(token)
+}
+
+// dpopProofKey scopes the jti to the proof key, since jti values are only unique per client.
+func dpopProofKey(thumbprint, jti string) string {
+	return "dpop:jti:" + utils.HashToken(thumbprint+":"+jti)
+}
+
+func revokedAccessTokenKey(jti string) string {
+	return revokedKeyPrefix + "jti:" + jti
+}
+
+func revokedSessionKey(sessionID string) string {
+	return revokedKeyPrefix + "sid:" + sessionID
+}
+
+func revokedUserKey(userID uint) string {
+	return fmt.Sprintf("%suser:%d", revokedKeyPrefix, userID)
+}
+
+func rotatedRefreshTokenKey(token string) string {
+	return "oauth:refresh_token_rotated:" + utils.HashToken(token)
+}
+
+func refreshFamilyKey(familyID string) string {
+	return "oauth:refresh_family:" + familyID
+}
+
+func deviceCodeKey(deviceCodeHash string) string {
+	return "oauth:device_code:" + deviceCodeHash
+}
+
+func devicePollKey(deviceCodeHash string) string {
+	return "oauth:device_poll:" + deviceCodeHash
+}
+
+func userCodeKey(userCode string) string {
+	return "oauth:user_code:" + userCode
+}
+
+func consentRequestKey(id string) string {
+	return "oauth:consent_request:" + utils.HashToken(id)
+}
+
+func pushedAuthorizationRequestKey(requestURI string) string {
+	return "oauth:par:" + utils.HashToken(requestURI)
+}
+
+func loginFlowKey(state string) string {
+	return "auth:login_flow:" + utils.HashToken(state)
+}
+
+func loginCodeKey(code string) string {
+	return "auth:login_code:" + utils.HashToken(code)
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+// TestDevicePollKeepsApproval interleaves a poll with the user's approval: the poll reads the
+// pending request, the approval is saved, then the poll records its polling state.
+func TestDevicePollKeepsApproval(t *testing.T) {
+	repo := NewTokenRepository(newTestRedisClient(t))
+
+	deviceCode, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceAuth := &entity.DeviceAuthorization{
+		DeviceCodeHash: utils.HashToken(deviceCode),
+		UserCode:       "BCDFGHJK",
+		ClientID:       "client",
+		Status:         entity.DeviceAuthorizationPending,
+		ExpiresAt:      time.Now().Add(time.Minute),
+	}
+	if err := repo.SaveDeviceAuthorization(deviceAuth, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.DeleteDeviceAuthorization(deviceAuth) })
+
+	polled, err := repo.FindDeviceAuthorization(deviceCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	approved := *deviceAuth
+	approved.Status = entity.DeviceAuthorizationApproved
+	approved.UserID = 1
+	if err := repo.SaveDeviceAuthorization(&approved, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	poll := &entity.DevicePoll{Interval: 5, LastPolledAt: time.Now()}
+	if err := repo.SaveDevicePoll(polled, poll, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := repo.FindDeviceAuthorization(deviceCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != entity.DeviceAuthorizationApproved || stored.UserID != 1 {
+		t.Errorf("after the poll, status = %q and user = %d, want the approval by user 1", stored.Status, stored.UserID)
+	}
+
+	storedPoll, err := repo.FindDevicePoll(polled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedPoll.Interval != poll.Interval || !storedPoll.LastPolledAt.Equal(poll.LastPolledAt) {
+		t.Errorf("FindDevicePoll() = %+v, want %+v", storedPoll, poll)
+	}
+}
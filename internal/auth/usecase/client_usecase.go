@@ -0,0 +1,285 @@
+package usecase
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+// ClientUseCase implements dynamic client registration (RFC 7591) and
+// client configuration management (RFC 7592).
+type ClientUseCase struct {
+	clientRepo repository.ClientRepository
+}
+
+func NewClientUseCase(clientRepo repository.ClientRepository) *ClientUseCase {
+	return &ClientUseCase{clientRepo: clientRepo}
+}
+
+// AuthorizeRegistration checks the initial access token configured in OAUTH_REGISTRATION_TOKEN.
+// Registration is disabled when it is not set.
+func (uc *ClientUseCase) AuthorizeRegistration(initialAccessToken string) error {
+	expected := config.GetEnv("OAUTH_REGISTRATION_TOKEN")
+	if expected == "" {
+		return &OAuthError{Code: "access_denied", Description: "client registration is disabled", Status: http.StatusForbidden}
+	}
+	if subtle.ConstantTimeCompare([]byte(initialAccessToken), []byte(expected)) != 1 {
+		return errInvalidToken("initial access token is invalid")
+	}
+	return nil
+}
+
+func (uc *ClientUseCase) Register(req dto.ClientRegistrationRequest) (*dto.ClientRegistrationResponse, error) {
+	client, err := clientFromMetadata(req)
+	if err != nil {
+		return nil, err
+	}
+
+	client.ClientID, err = utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, errServerError("failed to generate client_id")
+	}
+
+	var clientSecret string
+	if client.TokenEndpointAuthMethod != AuthMethodNone {
+		clientSecret, err = utils.GenerateRandomToken(32)
+		if err != nil {
+			return nil, errServerError("failed to generate client_secret")
+		}
+		client.ClientSecret, err = utils.HashPassword(clientSecret)
+		if err != nil {
+			return nil, errServerError("failed to hash client_secret")
+		}
+	}
+
+	registrationToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, errServerError("failed to generate registration access token")
+	}
+	client.RegistrationAccessToken = utils.HashToken(registrationToken)
+
+	if err := uc.clientRepo.Create(client); err != nil {
+		return nil, errServerError("failed to create client")
+	}
+
+	resp := registrationResponse(client)
+	resp.RegistrationAccessToken = registrationToken
+	if clientSecret != "" {
+		resp.ClientSecret = clientSecret
+		neverExpires := int64(0)
+		resp.ClientSecretExpiresAt = &neverExpires
+	}
+	return resp, nil
+}
+
+// FindRegisteredClient returns the client managed by the registration access token.
+func (uc *ClientUseCase) FindRegisteredClient(clientID, registrationToken string) (*dto.ClientRegistrationResponse, error) {
+	client, err := uc.authorizeConfiguration(clientID, registrationToken)
+	if err != nil {
+		return nil, err
+	}
+	return registrationResponse(client), nil
+}
+
+// UpdateRegisteredClient replaces the client metadata (RFC 7592 section 2.2).
+func (uc *ClientUseCase) UpdateRegisteredClient(clientID, registrationToken string, req dto.ClientRegistrationRequest) (*dto.ClientRegistrationResponse, error) {
+	existing, err := uc.authorizeConfiguration(clientID, registrationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ClientID != "" && req.ClientID != clientID {
+		return nil, errInvalidClientMetadata("client_id does not match the registration")
+	}
+
+	client, err := clientFromMetadata(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Switching between public and confidential would leave the client without usable credentials
+	if (client.TokenEndpointAuthMethod == AuthMethodNone) != existing.IsPublic() {
+		return nil, errInvalidClientMetadata("token_endpoint_auth_method cannot switch between public and confidential")
+	}
+
+	client.ClientID = existing.ClientID
+	if err := uc.clientRepo.Update(client); err != nil {
+		return nil, errServerError("failed to update client")
+	}
+
+	client.CreatedAt = existing.CreatedAt
+	return registrationResponse(client), nil
+}
+
+func (uc *ClientUseCase) DeleteRegisteredClient(clientID, registrationToken string) error {
+	if _, err := uc.authorizeConfiguration(clientID, registrationToken); err != nil {
+		return err
+	}
+
+	if err := uc.clientRepo.Delete(clientID); err != nil {
+		return errServerError("failed to delete client")
+	}
+	return nil
+}
+
+func (uc *ClientUseCase) authorizeConfiguration(clientID, registrationToken string) (*entity.OAuthClient, error) {
+	client, err := uc.clientRepo.FindByClientID(clientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrClientNotFound) {
+			// Do not reveal whether the client exists (RFC 7592 section 2.1)
+			return nil, errInvalidToken("registration access token is invalid")
+		}
+		return nil, errServerError("failed to find client")
+	}
+
+	if client.RegistrationAccessToken == "" || registrationToken == "" {
+		return nil, errInvalidToken("registration access token is invalid")
+	}
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(registrationToken)), []byte(client.RegistrationAccessToken)) != 1 {
+		return nil, errInvalidToken("registration access token is invalid")
+	}
+
+	return client, nil
+}
+
+// clientFromMetadata validates the registration request and applies the RFC 7591 defaults.
+func clientFromMetadata(req dto.ClientRegistrationRequest) (*entity.OAuthClient, error) {
+	authMethod := req.TokenEndpointAuthMethod
+	if authMethod == "" {
+		authMethod = AuthMethodClientSecretBasic
+	}
+	switch authMethod {
+	case AuthMethodClientSecretBasic, AuthMethodClientSecretPost, AuthMethodNone:
+	default:
+		return nil, errInvalidClientMetadata("token_endpoint_auth_method is not supported")
+	}
+
+	grantTypes := req.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{GrantTypeAuthorizationCode}
+	}
+	for _, grantType := range grantTypes {
+		switch grantType {
+		case GrantTypeAuthorizationCode, GrantTypeRefreshToken, GrantTypeDeviceCode:
+		case GrantTypeClientCredentials:
+			if authMethod == AuthMethodNone {
+				return nil, errInvalidClientMetadata("public clients cannot use the client_credentials grant")
+			}
+		default:
+			return nil, errInvalidClientMetadata("grant_type " + grantType + " is not supported")
+		}
+	}
+
+	scopes := parseScope(req.Scope)
+	if !scopesCovered(SupportedScopes(), scopes) {
+		return nil, errInvalidClientMetadata("scope contains a scope this server does not support")
+	}
+
+	client := &entity.OAuthClient{
+		Name:                    req.ClientName,
+		RedirectURIs:            req.RedirectURIs,
+		GrantTypes:              grantTypes,
+		Scopes:                  scopes,
+		TokenEndpointAuthMethod: authMethod,
+
+		RequirePushedAuthorizationRequests: req.RequirePushedAuthorizationRequests,
+	}
+	if client.RedirectURIs == nil {
+		client.RedirectURIs = []string{}
+	}
+
+	if client.AllowsGrantType(GrantTypeAuthorizationCode) && len(client.RedirectURIs) == 0 {
+		return nil, errInvalidRedirectURI("redirect_uris is required for the authorization_code grant")
+	}
+	for _, redirectURI := range client.RedirectURIs {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(req.JWKS) > 0 {
+		if _, err := parseClientJWKS(string(req.JWKS)); err != nil {
+			return nil, errInvalidClientMetadata("jwks is invalid: " + err.Error())
+		}
+		client.JWKS = string(req.JWKS)
+	}
+
+	return client, nil
+}
+
+// validateRedirectURI accepts https URIs, http URIs on the loopback interface and private-use
+// schemes of native apps in reverse domain name form such as com.example.app (RFC 8252 section
+// 7.1). Any other scheme, e.g. javascript: or data:, is rejected since the consent page
+// navigates to the redirect URI.
+func validateRedirectURI(redirectURI string) error {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || !parsed.IsAbs() {
+		return errInvalidRedirectURI("redirect_uri must be an absolute URI")
+	}
+	if parsed.Fragment != "" {
+		return errInvalidRedirectURI("redirect_uri must not contain a fragment")
+	}
+
+	switch parsed.Scheme {
+	case "https":
+	case "http":
+		if !isLoopback(parsed.Hostname()) {
+			return errInvalidRedirectURI("redirect_uri must use https")
+		}
+	default:
+		if !strings.Contains(parsed.Scheme, ".") {
+			return errInvalidRedirectURI("redirect_uri must use https or a private-use scheme in reverse domain name form")
+		}
+		return nil
+	}
+	if parsed.Host == "" {
+		return errInvalidRedirectURI("redirect_uri must have a host")
+	}
+	return nil
+}
+
+// parseClientJWKS decodes a client's key set, requiring every key to be a usable public key.
+func parseClientJWKS(data string) (utils.JWKS, error) {
+	var jwks utils.JWKS
+	if err := json.Unmarshal([]byte(data), &jwks); err != nil {
+		return utils.JWKS{}, err
+	}
+	if len(jwks.Keys) == 0 {
+		return utils.JWKS{}, errors.New("no keys")
+	}
+	for _, key := range jwks.Keys {
+		if _, err := key.PublicKey(); err != nil {
+			return utils.JWKS{}, err
+		}
+	}
+	return jwks, nil
+}
+
+func isLoopback(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+func registrationResponse(client *entity.OAuthClient) *dto.ClientRegistrationResponse {
+	return &dto.ClientRegistrationResponse{
+		ClientID:                client.ClientID,
+		ClientIDIssuedAt:        client.CreatedAt.Unix(),
+		RegistrationClientURI:   utils.Issuer() + "/oauth/register/" + client.ClientID,
+		ClientName:              client.Name,
+		RedirectURIs:            client.RedirectURIs,
+		GrantTypes:              client.GrantTypes,
+		TokenEndpointAuthMethod: client.TokenEndpointAuthMethod,
+		Scope:                   strings.Join(client.Scopes, " "),
+
+		JWKS:                               json.RawMessage(client.JWKS),
+		RequirePushedAuthorizationRequests: client.RequirePushedAuthorizationRequests,
+	}
+}
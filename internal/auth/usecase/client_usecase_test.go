@@ -0,0 +1,63 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+)
+
+func TestValidateRedirectURIAccepts(t *testing.T) {
+	for _, redirectURI := range []string{
+		"https://client.example.com/callback",
+		"https://client.example.com/callback?tenant=1",
+		"http://localhost:8080/callback",
+		"http://127.0.0.1:51234/callback",
+		"http://[::1]:51234/callback",
+		"com.example.app:/oauth2redirect",
+		"com.example.app://callback",
+	} {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			t.Errorf("validateRedirectURI(%q) = %v, want nil", redirectURI, err)
+		}
+	}
+}
+
+func TestValidateRedirectURIRejects(t *testing.T) {
+	for _, redirectURI := range []string{
+		"javascript:alert(document.cookie)",
+		"JavaScript:alert(1)",
+		"data:text/html,<script>alert(1)</script>",
+		"vbscript:msgbox(1)",
+		"file:///etc/passwd",
+		"myapp://callback",
+		"http://client.example.com/callback",
+		"https:callback",
+		"https://client.example.com/callback#fragment",
+		"/callback",
+	} {
+		if err := validateRedirectURI(redirectURI); err == nil {
+			t.Errorf("validateRedirectURI(%q) = nil, want an error", redirectURI)
+		}
+	}
+}
+
+func TestClientFromMetadataScopes(t *testing.T) {
+	t.Setenv("OAUTH_SCOPES", "orders:read")
+
+	req := dto.ClientRegistrationRequest{
+		ClientName:   "app",
+		RedirectURIs: []string{"https://client.example.com/callback"},
+		Scope:        "openid email orders:read",
+	}
+	if _, err := clientFromMetadata(req); err != nil {
+		t.Errorf("clientFromMetadata(scope %q) = %v, want nil", req.Scope, err)
+	}
+
+	for _, scope := range []string{"admin", "openid orders:write"} {
+		req.Scope = scope
+		_, err := clientFromMetadata(req)
+		if oauthErr, ok := err.(*OAuthError); !ok || oauthErr.Code != "invalid_client_metadata" {
+			t.Errorf("clientFromMetadata(scope %q) = %v, want invalid_client_metadata", scope, err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+const (
+	PromptNone    = "none"
+	PromptConsent = "consent"
+
+	consentRequestTTL = 10 * time.Minute
+)
+
+// ConsentRequest describes a pending authorization request so the user can see which scopes the client asks for.
+func (uc *OAuthUseCase) ConsentRequest(userID uint, consentID string) (*dto.ConsentRequestResponse, error) {
+	consentRequest, err := uc.findConsentRequest(userID, consentID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := uc.clientRepo.FindByClientID(consentRequest.Authorization.ClientID)
+	if err != nil {
+		return nil, errInvalidClient("client no longer exists")
+	}
+
+	return &dto.ConsentRequestResponse{
+		ConsentID:  consentRequest.ID,
+		ClientID:   client.ClientID,
+		ClientName: client.Name,
+		Scopes:     parseScope(consentRequest.Authorization.Scope),
+	}, nil
+}
+
+// CompleteConsent applies the user's decision. The consent request is returned together with
+// the authorization code, or with an access_denied error, so the caller can redirect to the client.
+func (uc *OAuthUseCase) CompleteConsent(userID uint, consentID string, approve bool) (*entity.ConsentRequest, string, error) {
+	if _, err := uc.findConsentRequest(userID, consentID); err != nil {
+		return nil, "", err
+	}
+
+	consentRequest, err := uc.tokenRepo.ConsumeConsentRequest(consentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return nil, "", errInvalidGrant("consent request is invalid or expired")
+		}
+		return nil, "", errServerError("failed to read consent request")
+	}
+
+	if !approve {
+		return consentRequest, "", errAccessDenied("the user denied the authorization request")
+	}
+
+	if err := uc.grantConsent(userID, consentRequest.Authorization.ClientID, parseScope(consentRequest.Authorization.Scope)); err != nil {
+		return nil, "", err
+	}
+
+	code, err := uc.issueAuthorizationCode(&consentRequest.Authorization)
+	if err != nil {
+		return nil, "", err
+	}
+	return consentRequest, code, nil
+}
+
+// hasConsent reports whether the user already granted the client every requested scope.
+func (uc *OAuthUseCase) hasConsent(userID uint, clientID string, scopes []string) (bool, error) {
+	consent, err := uc.consentRepo.Find(userID, clientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrConsentNotFound) {
+			return false, nil
+		}
+		return false, errServerError("failed to read consent")
+	}
+	return scopesCovered(consent.Scopes, scopes), nil
+}
+
+// grantConsent adds the approved scopes to those the user granted the client before.
+func (uc *OAuthUseCase) grantConsent(userID uint, clientID string, scopes []string) error {
+	consent, err := uc.consentRepo.Find(userID, clientID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrConsentNotFound) {
+			return errServerError("failed to read consent")
+		}
+		consent = &entity.Consent{UserID: userID, ClientID: clientID}
+	}
+
+	for _, scope := range scopes {
+		if !containsString(consent.Scopes, scope) {
+			consent.Scopes = append(consent.Scopes, scope)
+		}
+	}
+	if consent.Scopes == nil {
+		consent.Scopes = []string{}
+	}
+
+	if err := uc.consentRepo.Save(consent); err != nil {
+		return errServerError("failed to save consent")
+	}
+	return nil
+}
+
+func (uc *OAuthUseCase) requestConsent(authCode *entity.AuthorizationCode, state string) (string, error) {
+	consentID, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", errServerError("failed to generate consent request")
+	}
+
+	consentRequest := &entity.ConsentRequest{
+		ID:            consentID,
+		Authorization: *authCode,
+		State:         state,
+		ExpiresAt:     time.Now().Add(consentRequestTTL),
+	}
+	if err := uc.tokenRepo.SaveConsentRequest(consentRequest); err != nil {
+		return "", errServerError("failed to store consent request")
+	}
+	return consentID, nil
+}
+
+// findConsentRequest returns the pending request, which only the user who started it can see.
+func (uc *OAuthUseCase) findConsentRequest(userID uint, consentID string) (*entity.ConsentRequest, error) {
+	consentRequest, err := uc.tokenRepo.FindConsentRequest(consentID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return nil, errInvalidGrant("consent request is invalid or expired")
+		}
+		return nil, errServerError("failed to read consent request")
+	}
+
+	if consentRequest.Authorization.UserID != userID {
+		return nil, errInvalidGrant("consent request is invalid or expired")
+	}
+	return consentRequest, nil
+}
+
+// ConsentURI is the page where users approve or deny a consent request.
+func ConsentURI() string {
+	if uri := config.GetEnv("OAUTH_CONSENT_URI"); uri != "" {
+		return uri
+	}
+	return utils.Issuer() + "/oauth/consent"
+}
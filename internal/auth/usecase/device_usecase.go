@@ -0,0 +1,228 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+const (
+	GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+	deviceCodeTTL = 10 * time.Minute
+	// deviceCodeGrace keeps expired requests around so polling clients get expired_token instead of invalid_grant.
+	deviceCodeGrace    = 5 * time.Minute
+	devicePollInterval = 5
+	deviceSlowDownStep = 5
+
+	// userCodeAlphabet avoids vowels and look-alike characters (RFC 8628 section 6.1).
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+// DeviceAuthorization starts the device flow for a client that cannot receive a browser redirect.
+func (uc *OAuthUseCase) DeviceAuthorization(client *entity.OAuthClient, req dto.DeviceAuthorizationRequest) (*dto.DeviceAuthorizationResponse, error) {
+	if !client.AllowsGrantType(GrantTypeDeviceCode) {
+		return nil, errUnauthorizedClient("client is not allowed to use the device_code grant")
+	}
+
+	scopes := parseScope(req.Scope)
+	if !client.AllowsScopes(scopes) {
+		return nil, errInvalidScope("requested scope is not allowed for this client")
+	}
+
+	deviceCode, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, errServerError("failed to generate device code")
+	}
+	userCode, err := generateUserCode()
+	if err != nil {
+		return nil, errServerError("failed to generate user code")
+	}
+
+	deviceAuth := &entity.DeviceAuthorization{
+		DeviceCodeHash: utils.HashToken(deviceCode),
+		UserCode:       userCode,
+		ClientID:       client.ClientID,
+		Scope:          strings.Join(scopes, " "),
+		Status:         entity.DeviceAuthorizationPending,
+		ExpiresAt:      time.Now().Add(deviceCodeTTL),
+	}
+	if err := uc.tokenRepo.SaveDeviceAuthorization(deviceAuth, deviceCodeGrace); err != nil {
+		return nil, errServerError("failed to store device authorization")
+	}
+
+	verificationURI := deviceVerificationURI()
+	return &dto.DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                formatUserCode(userCode),
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(formatUserCode(userCode)),
+		ExpiresIn:               int64(deviceCodeTTL.Seconds()),
+		Interval:                devicePollInterval,
+	}, nil
+}
+
+// DeviceVerification describes a pending device request so the user can check what they approve.
+func (uc *OAuthUseCase) DeviceVerification(userCode string) (*dto.DeviceVerificationResponse, error) {
+	deviceAuth, err := uc.findPendingDeviceAuthorization(userCode)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := uc.clientRepo.FindByClientID(deviceAuth.ClientID)
+	if err != nil {
+		return nil, errInvalidClient("client no longer exists")
+	}
+
+	return &dto.DeviceVerificationResponse{
+		UserCode:   formatUserCode(deviceAuth.UserCode),
+		ClientID:   client.ClientID,
+		ClientName: client.Name,
+		Scope:      deviceAuth.Scope,
+	}, nil
+}
+
+// CompleteDeviceVerification records the logged-in user's decision for a user code.
+func (uc *OAuthUseCase) CompleteDeviceVerification(userID uint, userCode string, approve bool) error {
+	deviceAuth, err := uc.findPendingDeviceAuthorization(userCode)
+	if err != nil {
+		return err
+	}
+
+	if approve {
+		deviceAuth.Status = entity.DeviceAuthorizationApproved
+		deviceAuth.UserID = userID
+	} else {
+		deviceAuth.Status = entity.DeviceAuthorizationDenied
+	}
+
+	if err := uc.tokenRepo.SaveDeviceAuthorization(deviceAuth, deviceCodeGrace); err != nil {
+		return errServerError("failed to store device authorization")
+	}
+	return nil
+}
+
+// exchangeDeviceCode answers a polling device. Polling faster than the interval
+// returns slow_down and widens the interval by 5 seconds (RFC 8628 section 3.5).
+func (uc *OAuthUseCase) exchangeDeviceCode(client *entity.OAuthClient, req dto.TokenRequest) (*dto.TokenResponse, error) {
+	if req.DeviceCode == "" {
+		return nil, errInvalidRequest("device_code is required")
+	}
+
+	deviceAuth, err := uc.tokenRepo.FindDeviceAuthorization(req.DeviceCode)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return nil, errInvalidGrant("device code is invalid")
+		}
+		return nil, errServerError("failed to read device authorization")
+	}
+
+	if deviceAuth.ClientID != client.ClientID {
+		return nil, errInvalidGrant("device code was issued to another client")
+	}
+
+	now := time.Now()
+	if now.After(deviceAuth.ExpiresAt) {
+		return nil, errExpiredToken("device code has expired")
+	}
+
+	if deviceAuth.Status == entity.DeviceAuthorizationPending {
+		// Only the polling state is written, the user may be approving the request right now
+		poll, err := uc.tokenRepo.FindDevicePoll(deviceAuth)
+		if err != nil {
+			if !errors.Is(err, repository.ErrTokenNotFound) {
+				return nil, errServerError("failed to read device polling state")
+			}
+			poll = &entity.DevicePoll{Interval: devicePollInterval}
+		}
+
+		tooFast := now.Before(poll.LastPolledAt.Add(time.Duration(poll.Interval) * time.Second))
+		if tooFast {
+			poll.Interval += deviceSlowDownStep
+		}
+		poll.LastPolledAt = now
+		if err := uc.tokenRepo.SaveDevicePoll(deviceAuth, poll, deviceCodeGrace); err != nil {
+			return nil, errServerError("failed to store device polling state")
+		}
+
+		if tooFast {
+			return nil, errSlowDown("polling too frequently")
+		}
+		return nil, errAuthorizationPending("the user has not yet completed authorization")
+	}
+
+	deleted, err := uc.tokenRepo.DeleteDeviceAuthorization(deviceAuth)
+	if err != nil {
+		return nil, errServerError("failed to delete device authorization")
+	}
+	if !deleted {
+		return nil, errInvalidGrant("device code has already been used")
+	}
+
+	if deviceAuth.Status == entity.DeviceAuthorizationDenied {
+		return nil, errAccessDenied("the user denied the authorization request")
+	}
+
+	return uc.issueTokens(client, userGrant{userID: deviceAuth.UserID, scope: deviceAuth.Scope, jkt: req.DPoPKeyThumbprint})
+}
+
+func (uc *OAuthUseCase) findPendingDeviceAuthorization(userCode string) (*entity.DeviceAuthorization, error) {
+	deviceAuth, err := uc.tokenRepo.FindDeviceAuthorizationByUserCode(normalizeUserCode(userCode))
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return nil, errInvalidGrant("user code is invalid or expired")
+		}
+		return nil, errServerError("failed to read device authorization")
+	}
+
+	if deviceAuth.Status != entity.DeviceAuthorizationPending || time.Now().After(deviceAuth.ExpiresAt) {
+		return nil, errInvalidGrant("user code is invalid or expired")
+	}
+	return deviceAuth, nil
+}
+
+func deviceVerificationURI() string {
+	if uri := config.GetEnv("DEVICE_VERIFICATION_URI"); uri != "" {
+		return uri
+	}
+	return utils.Issuer() + "/oauth/device"
+}
+
+func generateUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// formatUserCode splits the code in two groups for readability, e.g. WDJB-MJHT.
+func formatUserCode(userCode string) string {
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
+
+// normalizeUserCode accepts user input in any case and with or without separators.
+func normalizeUserCode(userCode string) string {
+	userCode = strings.ToUpper(userCode)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, userCode)
+}
@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+const (
+	TokenTypeBearer = "Bearer"
+	TokenTypeDPoP   = "DPoP"
+)
+
+// VerifyDPoPProof checks a DPoP proof made for method and uri and records its jti, so the same
+// proof is never accepted twice. It returns the JWK thumbprint of the key that signed the proof.
+// accessToken is the token the proof is presented with, empty at the token endpoint.
+func VerifyDPoPProof(tokenRepo repository.TokenRepository, proof, method, uri, accessToken string) (string, error) {
+	parsed, err := utils.ParseDPoPProof(proof, method, uri, accessToken)
+	if err != nil {
+		return "", err
+	}
+
+	fresh, err := tokenRepo.MarkDPoPProofUsed(parsed.Thumbprint, parsed.ID, utils.DPoPProofMaxAge()+2*utils.ClockSkew())
+	if err != nil {
+		return "", err
+	}
+	if !fresh {
+		return "", fmt.Errorf("%w: jti has already been used", utils.ErrInvalidDPoPProof)
+	}
+	return parsed.Thumbprint, nil
+}
+
+func (uc *OAuthUseCase) VerifyDPoPProof(proof, method, uri string) (string, error) {
+	return VerifyDPoPProof(uc.tokenRepo, proof, method, uri, "")
+}
+
+func (uc *AuthUseCase) VerifyDPoPProof(proof, method, uri string) (string, error) {
+	return VerifyDPoPProof(uc.tokenRepo, proof, method, uri, "")
+}
+
+// DPoPURI is the htu expected in proofs for a request to path. It is based on the issuer so the
+// check does not depend on the Host header seen behind a proxy.
+func DPoPURI(path string) string {
+	return utils.Issuer() + path
+}
+
+// BoundKeyThumbprint returns the cnf.jkt claim of a DPoP-bound access token, or "" for bearer tokens.
+func BoundKeyThumbprint(claims jwt.MapClaims) string {
+	cnf, _ := claims["cnf"].(map[string]interface{})
+	jkt, _ := cnf["jkt"].(string)
+	return jkt
+}
+
+// bindToKey adds the confirmation claim binding the access token to a DPoP key (RFC 9449 section 6).
+func bindToKey(claims jwt.MapClaims, jkt string) {
+	if jkt != "" {
+		claims["cnf"] = map[string]interface{}{"jkt": jkt}
+	}
+}
+
+func accessTokenType(jkt string) string {
+	if jkt != "" {
+		return TokenTypeDPoP
+	}
+	return TokenTypeBearer
+}
@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// SupportedScopes lists the scopes advertised in discovery: the OpenID Connect scopes and the
+// API scopes in OAUTH_SCOPES. Dynamically registered clients cannot claim any other scope.
+func SupportedScopes() []string {
+	return append([]string{ScopeOpenID, ScopeProfile, ScopeEmail}, parseScope(config.GetEnv("OAUTH_SCOPES"))...)
+}
+
+// ID tokens are signed with the same keys as access tokens, so they carry token_use=id and
+// ParseAccessToken refuses them.
+const (
+	tokenUseClaim   = "token_use"
+	tokenUseIDToken = "id"
+)
+
+// UserInfo returns the claims about the user that the access token's scopes allow.
+// Routes must require the openid scope.
+func (uc *OAuthUseCase) UserInfo(userID uint, scopes []string) (map[string]interface{}, error) {
+	user, err := uc.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errInvalidToken("user no longer exists")
+	}
+
+	return userClaims(user, scopes), nil
+}
+
+func generateIDToken(client *entity.OAuthClient, user *entity.User, grant userGrant, accessToken string) (string, error) {
+	key := utils.CurrentKeySet().Active()
+	if key == nil {
+		return "", utils.ErrNoSigningKey
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":     utils.Issuer(),
+		"aud":     client.ClientID,
+		"iat":     now.Unix(),
+		"exp":     now.Add(utils.JWTExpiration()).Unix(),
+		"at_hash": utils.AccessTokenHash(accessToken, key.Algorithm),
+	}
+	claims[tokenUseClaim] = tokenUseIDToken
+	if grant.nonce != "" {
+		claims["nonce"] = grant.nonce
+	}
+	if grant.authTime != 0 {
+		claims["auth_time"] = grant.authTime
+	}
+	for name, value := range userClaims(user, parseScope(grant.scope)) {
+		claims[name] = value
+	}
+
+	return utils.SignJWT(claims)
+}
+
+// userClaims maps the user to the standard OpenID Connect claims granted by the profile and email scopes.
+func userClaims(user *entity.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": strconv.FormatUint(uint64(user.ID), 10),
+	}
+	if containsString(scopes, ScopeProfile) {
+		claims["name"] = user.Name
+	}
+	if containsString(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	return claims
+}
@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+)
+
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// Introspect reports whether a token is currently active (RFC 7662). The hint only
+// decides which token type is tried first, both are always checked.
+func (uc *OAuthUseCase) Introspect(client *entity.OAuthClient, req dto.IntrospectionRequest) (*dto.IntrospectionResponse, error) {
+	if client.IsPublic() {
+		return nil, errInvalidClient("public clients cannot introspect tokens")
+	}
+
+	lookups := []func(string) (*dto.IntrospectionResponse, error){uc.introspectAccessToken, uc.introspectRefreshToken}
+	if req.TokenTypeHint == TokenTypeHintRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		resp, err := lookup(req.Token)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Active {
+			return resp, nil
+		}
+	}
+
+	return &dto.IntrospectionResponse{Active: false}, nil
+}
+
+func (uc *OAuthUseCase) introspectAccessToken(token string) (*dto.IntrospectionResponse, error) {
+	claims, err := ParseAccessToken(uc.tokenRepo, token, "")
+	if err != nil {
+		return &dto.IntrospectionResponse{Active: false}, nil
+	}
+
+	revoked, err := uc.isAccessTokenRevoked(claims)
+	if err != nil {
+		return nil, errServerError("failed to check token revocation")
+	}
+	if revoked {
+		return &dto.IntrospectionResponse{Active: false}, nil
+	}
+
+	resp := &dto.IntrospectionResponse{
+		Active:    true,
+		TokenType: accessTokenType(BoundKeyThumbprint(claims)),
+		Sub:       subjectFromClaims(claims),
+		Exp:       int64Claim(claims, "exp"),
+		Iat:       int64Claim(claims, "iat"),
+	}
+	resp.Scope, _ = claims["scope"].(string)
+	resp.ClientID, _ = claims["client_id"].(string)
+	resp.Aud = audienceClaim(claims["aud"])
+	resp.Act, _ = claims["act"].(map[string]interface{})
+	resp.Cnf, _ = claims["cnf"].(map[string]interface{})
+
+	return resp, nil
+}
+
+func (uc *OAuthUseCase) introspectRefreshToken(token string) (*dto.IntrospectionResponse, error) {
+	refreshToken, err := uc.tokenRepo.FindRefreshToken(token)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return &dto.IntrospectionResponse{Active: false}, nil
+		}
+		return nil, errServerError("failed to read refresh token")
+	}
+	if refreshToken.Rotated {
+		return &dto.IntrospectionResponse{Active: false}, nil
+	}
+
+	return &dto.IntrospectionResponse{
+		Active:    true,
+		TokenType: TokenTypeHintRefreshToken,
+		Scope:     refreshToken.Scope,
+		ClientID:  refreshToken.ClientID,
+		Sub:       strconv.FormatUint(uint64(refreshToken.UserID), 10),
+		Exp:       refreshToken.ExpiresAt.Unix(),
+		Iat:       refreshToken.IssuedAt.Unix(),
+	}, nil
+}
+
+// subjectFromClaims returns the user ID for user tokens and the client ID for client_credentials tokens.
+func subjectFromClaims(claims jwt.MapClaims) string {
+	if userID, ok := claims["user_id"].(float64); ok {
+		return strconv.FormatUint(uint64(userID), 10)
+	}
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+func int64Claim(claims jwt.MapClaims, name string) int64 {
+	value, _ := claims[name].(float64)
+	return int64(value)
+}
+
+// audienceClaim copies aud whether the token carries a single string or an array of strings.
+func audienceClaim(aud interface{}) dto.Audience {
+	switch aud := aud.(type) {
+	case string:
+		return dto.Audience{aud}
+	case []interface{}:
+		audience := make(dto.Audience, 0, len(aud))
+		for _, value := range aud {
+			if value, ok := value.(string); ok {
+				audience = append(audience, value)
+			}
+		}
+		return audience
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+func setTestSigningKey(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("OAUTH_ISSUER", "https://auth.example.com")
+	key, err := utils.GenerateSigningKey("ES256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := utils.CurrentKeySet()
+	utils.SetKeySet(utils.NewKeySet(key))
+	t.Cleanup(func() { utils.SetKeySet(previous) })
+}
+
+func TestIntrospectIDTokenIsInactive(t *testing.T) {
+	setTestSigningKey(t)
+
+	client := &entity.OAuthClient{ClientID: "client", ClientSecret: "secret"}
+	user := &entity.User{ID: 1, Email: "user@example.com"}
+	idToken, err := generateIDToken(client, user, userGrant{userID: user.ID, scope: "openid email"}, "access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the access token lookup runs, the refresh token lookup needs Redis
+	uc := NewOAuthUseCase(repository.UserRepository{}, repository.ClientRepository{}, repository.TokenRepository{}, repository.ConsentRepository{})
+	resp, err := uc.introspectAccessToken(idToken)
+	if err != nil {
+		t.Fatalf("introspectAccessToken() = %v", err)
+	}
+	if resp.Active {
+		t.Errorf("introspectAccessToken() of an ID token = %+v, want active false", resp)
+	}
+}
+
+func TestParseAccessTokenRejectsOtherTokens(t *testing.T) {
+	setTestSigningKey(t)
+
+	clientClaims, err := utils.ClientAccessTokenClaims("client", "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, err := utils.SignJWT(clientClaims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseAccessToken(repository.TokenRepository{}, accessToken, ""); err != nil {
+		t.Errorf("ParseAccessToken() of a client access token = %v, want nil", err)
+	}
+
+	// Signed by this server and unexpired, but issued to neither a user nor a client
+	claims, err := utils.ClientAccessTokenClaims("client", "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(claims, "client_id")
+	token, err := utils.SignJWT(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseAccessToken(repository.TokenRepository{}, token, ""); err == nil {
+		t.Error("ParseAccessToken() of a token without user_id or client_id = nil, want an error")
+	}
+}
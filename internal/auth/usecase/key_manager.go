@@ -0,0 +1,229 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+const (
+	// keyPrepublishWindow lets verifiers fetch a new key from the JWKS endpoint before it signs anything.
+	keyPrepublishWindow = 10 * time.Minute
+	// keyExpiryLeeway keeps a retired key verifiable a little longer than its last token could live.
+	keyExpiryLeeway = 5 * time.Minute
+	// keyHistoryRetention keeps expired and revoked keys listed for auditing before deleting them.
+	keyHistoryRetention = 7 * 24 * time.Hour
+)
+
+// KeyManager persists signing keys in Postgres, rotates them on a schedule and
+// publishes the current set to utils for signing and verification.
+type KeyManager struct {
+	keyRepo repository.KeyRepository
+}
+
+func NewKeyManager(keyRepo repository.KeyRepository) *KeyManager {
+	return &KeyManager{keyRepo: keyRepo}
+}
+
+// Load publishes the stored keys, creating the first key when none can sign.
+func (m *KeyManager) Load() error {
+	keys, err := m.keyRepo.FindAll()
+	if err != nil {
+		return err
+	}
+
+	if activeKey(keys, time.Now()) == nil {
+		// Import JWT_PRIVATE_KEY_FILE when set, otherwise a new key is generated
+		key, err := utils.LoadSigningKey()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		record, err := toEntityKey(key, now)
+		if err != nil {
+			return err
+		}
+		// Another instance may have created it meanwhile, in which case that key is loaded
+		if _, err := m.keyRepo.CreateFirst(record, now); err != nil {
+			return err
+		}
+
+		keys, err = m.keyRepo.FindAll()
+		if err != nil {
+			return err
+		}
+	}
+
+	return publishKeys(keys)
+}
+
+func (m *KeyManager) List() ([]*entity.SigningKey, error) {
+	return m.keyRepo.FindAll()
+}
+
+// Rotate schedules a new key to take over signing after the prepublish window.
+// The previous keys stay verify-only until the tokens they signed have expired.
+func (m *KeyManager) Rotate() (*entity.SigningKey, error) {
+	return m.rotate(time.Now().Add(keyPrepublishWindow))
+}
+
+// Revoke immediately stops accepting tokens signed with kid. When kid is the
+// active key a replacement is activated right away; until it is, nothing is signed.
+func (m *KeyManager) Revoke(kid string) error {
+	keys, err := m.keyRepo.FindAll()
+	if err != nil {
+		return err
+	}
+
+	active := activeKey(keys, time.Now())
+
+	if err := m.keyRepo.Revoke(kid, time.Now()); err != nil {
+		return err
+	}
+	log.Printf("security event: signing key %s revoked", kid)
+	// Stop signing and verifying with the key here even if the replacement below fails
+	utils.SetKeySet(utils.CurrentKeySet().Without(kid))
+
+	if active != nil && active.KeyID == kid {
+		_, err := m.rotate(time.Now())
+		return err
+	}
+
+	return m.Load()
+}
+
+// Run reloads the key set periodically so revocations and rotations made by
+// other instances are picked up, and rotates when the active key is due.
+func (m *KeyManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(config.GetEnvDuration("JWT_KEY_RELOAD_INTERVAL", time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.tick(); err != nil {
+				log.Printf("Failed to refresh signing keys: %v", err)
+			}
+		}
+	}
+}
+
+func (m *KeyManager) tick() error {
+	keys, err := m.keyRepo.FindAll()
+	if err != nil {
+		return err
+	}
+
+	if rotationDue(keys, time.Now()) {
+		if _, err := m.Rotate(); err != nil && !errors.Is(err, repository.ErrRotationInProgress) {
+			return err
+		}
+	}
+
+	if err := m.keyRepo.DeleteExpired(time.Now().Add(-keyHistoryRetention)); err != nil {
+		return err
+	}
+
+	return m.Load()
+}
+
+func (m *KeyManager) rotate(activatesAt time.Time) (*entity.SigningKey, error) {
+	key, err := utils.GenerateSigningKey(utils.SigningAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := toEntityKey(key, activatesAt)
+	if err != nil {
+		return nil, err
+	}
+
+	retireAt := activatesAt.Add(utils.JWTExpiration() + keyExpiryLeeway)
+	if err := m.keyRepo.Rotate(record, retireAt); err != nil {
+		return nil, err
+	}
+	log.Printf("Signing key %s created, active from %s", record.KeyID, activatesAt.Format(time.RFC3339))
+
+	return record, m.Load()
+}
+
+// rotationDue reports whether the newest key is older than JWT_KEY_ROTATION_INTERVAL.
+// A key waiting in the prepublish window counts as the newest, so rotations never pile up.
+func rotationDue(keys []*entity.SigningKey, now time.Time) bool {
+	interval := config.GetEnvDuration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour)
+	if interval <= 0 {
+		return false
+	}
+
+	for _, key := range keys {
+		if key.RevokedAt == nil {
+			return !now.Before(key.ActivatesAt.Add(interval))
+		}
+	}
+	return false
+}
+
+// activeKey returns the most recently activated key that can sign. Keys are ordered newest first.
+func activeKey(keys []*entity.SigningKey, now time.Time) *entity.SigningKey {
+	for _, key := range keys {
+		if key.CanSign(now) {
+			return key
+		}
+	}
+	return nil
+}
+
+func publishKeys(keys []*entity.SigningKey) error {
+	now := time.Now()
+	active := activeKey(keys, now)
+	if active == nil {
+		return errors.New("no active signing key")
+	}
+
+	var (
+		signingKey *utils.SigningKey
+		verifyKeys []*utils.SigningKey
+	)
+	for _, key := range keys {
+		if !key.CanVerify(now) {
+			continue
+		}
+
+		privateKey, err := utils.ParsePrivateKeyPEM([]byte(key.PrivateKey))
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", key.KeyID, err)
+		}
+		parsed := &utils.SigningKey{ID: key.KeyID, Algorithm: key.Algorithm, PrivateKey: privateKey}
+
+		if key == active {
+			signingKey = parsed
+		} else {
+			verifyKeys = append(verifyKeys, parsed)
+		}
+	}
+
+	utils.SetKeySet(utils.NewKeySet(signingKey, verifyKeys...))
+	return nil
+}
+
+func toEntityKey(key *utils.SigningKey, activatesAt time.Time) (*entity.SigningKey, error) {
+	pemBytes, err := utils.MarshalPrivateKeyPEM(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.SigningKey{
+		KeyID:       key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  string(pemBytes),
+		ActivatesAt: activatesAt,
+	}, nil
+}
@@ -0,0 +1,95 @@
+package usecase
+
+import "net/http"
+
+// OAuthError is an error response defined by RFC 6749, carrying the HTTP status the endpoint should use.
+type OAuthError struct {
+	Code        string
+	Description string
+	Status      int
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func errInvalidRequest(description string) *OAuthError {
+	return &OAuthError{Code: "invalid_request", Description: description, Status: http.StatusBadRequest}
+}
+
+func errInvalidClient(description string) *OAuthError {
+	return &OAuthError{Code: "invalid_client", Description: description, Status: http.StatusUnauthorized}
+}
+
+func errInvalidGrant(description string) *OAuthError {
+	return &OAuthError{Code: "invalid_grant", Description: description, Status: http.StatusBadRequest}
+}
+
+func errUnauthorizedClient(description string) *OAuthError {
+	return &OAuthError{Code: "unauthorized_client", Description: description, Status: http.StatusBadRequest}
+}
+
+func errConsentRequired(description string) *OAuthError {
+	return &OAuthError{Code: "consent_required", Description: description, Status: http.StatusBadRequest}
+}
+
+func errLoginRequired(description string) *OAuthError {
+	return &OAuthError{Code: "login_required", Description: description, Status: http.StatusBadRequest}
+}
+
+func errAccessDenied(description string) *OAuthError {
+	return &OAuthError{Code: "access_denied", Description: description, Status: http.StatusBadRequest}
+}
+
+func errUnsupportedGrantType(description string) *OAuthError {
+	return &OAuthError{Code: "unsupported_grant_type", Description: description, Status: http.StatusBadRequest}
+}
+
+func errUnsupportedResponseType(description string) *OAuthError {
+	return &OAuthError{Code: "unsupported_response_type", Description: description, Status: http.StatusBadRequest}
+}
+
+func errInvalidScope(description string) *OAuthError {
+	return &OAuthError{Code: "invalid_scope", Description: description, Status: http.StatusBadRequest}
+}
+
+// errInvalidTarget rejects a token exchange for an audience the client may not request (RFC 8693 section 2.2.2).
+func errInvalidTarget(description string) *OAuthError {
+	return &OAuthError{Code: "invalid_target", Description: description, Status: http.StatusBadRequest}
+}
+
+func errInvalidRequestObject(description string) *OAuthError {
+	return &OAuthError{Code: "invalid_request_object", Description: description, Status: http.StatusBadRequest}
+}
+
+func errInvalidRequestURI(description string) *OAuthError {
+	return &OAuthError{Code: "invalid_request_uri", Description: description, Status: http.StatusBadRequest}
+}
+
+func errServerError(description string) *OAuthError {
+	return &OAuthError{Code: "server_error", Description: description, Status: http.StatusInternalServerError}
+}
+
+func errAuthorizationPending(description string) *OAuthError {
+	return &OAuthError{Code: "authorization_pending", Description: description, Status: http.StatusBadRequest}
+}
+
+func errSlowDown(description string) *OAuthError {
+	return &OAuthError{Code: "slow_down", Description: description, Status: http.StatusBadRequest}
+}
+
+func errExpiredToken(description string) *OAuthError {
+	return &OAuthError{Code: "expired_token", Description: description, Status: http.StatusBadRequest}
+}
+
+func errInvalidToken(description string) *OAuthError {
+	return &OAuthError{Code: "invalid_token", Description: description, Status: http.StatusUnauthorized}
+}
+
+func errInvalidRedirectURI(description string) *OAuthError {
+	return &OAuthError{Code: "invalid_redirect_uri", Description: description, Status: http.StatusBadRequest}
+}
+
+func errInvalidClientMetadata(description string) *OAuthError {
+	return &OAuthError{Code: "invalid_client_metadata", Description: description, Status: http.StatusBadRequest}
+}
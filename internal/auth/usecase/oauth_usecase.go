@@ -0,0 +1,456 @@
+package usecase
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+	"golang.org/x/oauth2"
+)
+
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+
+	ResponseTypeCode        = "code"
+	CodeChallengeMethodS256 = "S256"
+
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodNone              = "none"
+
+	authorizationCodeTTL = 5 * time.Minute
+)
+
+type OAuthUseCase struct {
+	userRepo    repository.UserRepository
+	clientRepo  repository.ClientRepository
+	tokenRepo   repository.TokenRepository
+	consentRepo repository.ConsentRepository
+}
+
+func NewOAuthUseCase(userRepo repository.UserRepository, clientRepo repository.ClientRepository, tokenRepo repository.TokenRepository, consentRepo repository.ConsentRepository) *OAuthUseCase {
+	return &OAuthUseCase{userRepo: userRepo, clientRepo: clientRepo, tokenRepo: tokenRepo, consentRepo: consentRepo}
+}
+
+// ValidateClientRedirect checks the client and redirect URI of an authorization request.
+// Errors returned here must be shown to the user instead of being redirected to the client.
+func (uc *OAuthUseCase) ValidateClientRedirect(clientID, redirectURI string) (*entity.OAuthClient, error) {
+	if clientID == "" {
+		return nil, errInvalidRequest("client_id is required")
+	}
+
+	client, err := uc.clientRepo.FindByClientID(clientID)
+	if err != nil {
+		return nil, errInvalidRequest("client_id is invalid")
+	}
+
+	if redirectURI == "" || !client.HasRedirectURI(redirectURI) {
+		return nil, errInvalidRequest("redirect_uri is not registered for this client")
+	}
+
+	return client, nil
+}
+
+// AuthorizeResult is the outcome of an authorization request: either a code for the
+// client, or the ID of a consent request the user has to decide on first.
+type AuthorizeResult struct {
+	Code      string
+	ConsentID string
+}
+
+// Authorize handles an authorization request for the logged-in user. authTime is when
+// the user authenticated, or zero when unknown. userID is zero when nobody is logged in.
+func (uc *OAuthUseCase) Authorize(userID uint, authTime int64, client *entity.OAuthClient, req dto.AuthorizeRequest) (*AuthorizeResult, error) {
+	if err := validateAuthorizeRequest(client, req); err != nil {
+		return nil, err
+	}
+	if userID == 0 {
+		return nil, errLoginRequired("the user is not logged in")
+	}
+
+	prompts := parseScope(req.Prompt)
+	scopes := parseScope(req.Scope)
+
+	if _, err := uc.userRepo.FindByID(userID); err != nil {
+		return nil, errAccessDenied("resource owner not found")
+	}
+
+	authCode := &entity.AuthorizationCode{
+		ClientID:            client.ClientID,
+		UserID:              userID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               strings.Join(scopes, " "),
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
+		AuthTime:            authTime,
+	}
+
+	granted, err := uc.hasConsent(userID, client.ClientID, scopes)
+	if err != nil {
+		return nil, err
+	}
+	if !granted || containsString(prompts, PromptConsent) {
+		if containsString(prompts, PromptNone) {
+			return nil, errConsentRequired("the user has not approved the requested scopes")
+		}
+		consentID, err := uc.requestConsent(authCode, req.State)
+		if err != nil {
+			return nil, err
+		}
+		return &AuthorizeResult{ConsentID: consentID}, nil
+	}
+
+	code, err := uc.issueAuthorizationCode(authCode)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthorizeResult{Code: code}, nil
+}
+
+// validateAuthorizeRequest checks the parameters of an authorization request, except for the
+// redirect_uri which is validated before any error may be redirected to it.
+func validateAuthorizeRequest(client *entity.OAuthClient, req dto.AuthorizeRequest) error {
+	if req.ResponseType != ResponseTypeCode {
+		return errUnsupportedResponseType("response_type must be code")
+	}
+
+	if !client.AllowsGrantType(GrantTypeAuthorizationCode) {
+		return errUnauthorizedClient("client is not allowed to use the authorization_code grant")
+	}
+
+	// PKCE is mandatory for every client, confidential or public
+	if req.CodeChallenge == "" {
+		return errInvalidRequest("code_challenge is required")
+	}
+	if req.CodeChallengeMethod != CodeChallengeMethodS256 {
+		return errInvalidRequest("code_challenge_method must be S256")
+	}
+
+	prompts := parseScope(req.Prompt)
+	if containsString(prompts, PromptNone) && len(prompts) > 1 {
+		return errInvalidRequest("prompt=none cannot be combined with other values")
+	}
+
+	if !client.AllowsScopes(parseScope(req.Scope)) {
+		return errInvalidScope("requested scope is not allowed for this client")
+	}
+	return nil
+}
+
+func (uc *OAuthUseCase) issueAuthorizationCode(authCode *entity.AuthorizationCode) (string, error) {
+	code, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", errServerError("failed to generate authorization code")
+	}
+
+	authCode.ExpiresAt = time.Now().Add(authorizationCodeTTL)
+	if err := uc.tokenRepo.SaveAuthorizationCode(code, authCode); err != nil {
+		return "", errServerError("failed to store authorization code")
+	}
+
+	return code, nil
+}
+
+// AuthenticateClient verifies the credentials presented at the token endpoint.
+// Public clients have no secret and are identified by client_id alone. Clients with a
+// registered token_endpoint_auth_method must use that method.
+func (uc *OAuthUseCase) AuthenticateClient(clientID, clientSecret, authMethod string) (*entity.OAuthClient, error) {
+	if clientID == "" {
+		return nil, errInvalidClient("client authentication is required")
+	}
+
+	client, err := uc.clientRepo.FindByClientID(clientID)
+	if err != nil {
+		return nil, errInvalidClient("client authentication failed")
+	}
+
+	if client.IsPublic() {
+		return client, nil
+	}
+
+	if client.TokenEndpointAuthMethod != "" && client.TokenEndpointAuthMethod != authMethod {
+		return nil, errInvalidClient("client must authenticate with " + client.TokenEndpointAuthMethod)
+	}
+
+	if clientSecret == "" || !utils.CheckPasswordHash(clientSecret, client.ClientSecret) {
+		return nil, errInvalidClient("client authentication failed")
+	}
+
+	return client, nil
+}
+
+func (uc *OAuthUseCase) Token(client *entity.OAuthClient, req dto.TokenRequest) (*dto.TokenResponse, error) {
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode, GrantTypeRefreshToken, GrantTypeClientCredentials, GrantTypeDeviceCode, GrantTypeTokenExchange:
+	case "":
+		return nil, errInvalidRequest("grant_type is required")
+	default:
+		return nil, errUnsupportedGrantType("grant_type is not supported")
+	}
+
+	if !client.AllowsGrantType(req.GrantType) {
+		return nil, errUnauthorizedClient("client is not allowed to use this grant_type")
+	}
+
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode:
+		return uc.exchangeAuthorizationCode(client, req)
+	case GrantTypeClientCredentials:
+		return uc.clientCredentials(client, req)
+	case GrantTypeDeviceCode:
+		return uc.exchangeDeviceCode(client, req)
+	case GrantTypeTokenExchange:
+		return uc.exchangeToken(client, req)
+	default:
+		return uc.refreshAccessToken(client, req)
+	}
+}
+
+func (uc *OAuthUseCase) exchangeAuthorizationCode(client *entity.OAuthClient, req dto.TokenRequest) (*dto.TokenResponse, error) {
+	if req.Code == "" {
+		return nil, errInvalidRequest("code is required")
+	}
+	if req.CodeVerifier == "" {
+		return nil, errInvalidRequest("code_verifier is required")
+	}
+
+	authCode, err := uc.tokenRepo.ConsumeAuthorizationCode(req.Code)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return nil, errInvalidGrant("authorization code is invalid or expired")
+		}
+		return nil, errServerError("failed to read authorization code")
+	}
+
+	if authCode.ClientID != client.ClientID {
+		return nil, errInvalidGrant("authorization code was issued to another client")
+	}
+	if authCode.RedirectURI != req.RedirectURI {
+		return nil, errInvalidGrant("redirect_uri does not match the authorization request")
+	}
+	if time.Now().After(authCode.ExpiresAt) {
+		return nil, errInvalidGrant("authorization code is invalid or expired")
+	}
+	if !verifyCodeChallenge(req.CodeVerifier, authCode.CodeChallenge) {
+		return nil, errInvalidGrant("code_verifier does not match the code_challenge")
+	}
+
+	return uc.issueTokens(client, userGrant{
+		userID:   authCode.UserID,
+		scope:    authCode.Scope,
+		nonce:    authCode.Nonce,
+		authTime: authCode.AuthTime,
+		jkt:      req.DPoPKeyThumbprint,
+	})
+}
+
+// clientCredentials issues a token on the client's own behalf. No refresh token is
+// returned since the client can always authenticate again (RFC 6749 section 4.4.3).
+func (uc *OAuthUseCase) clientCredentials(client *entity.OAuthClient, req dto.TokenRequest) (*dto.TokenResponse, error) {
+	if client.IsPublic() {
+		return nil, errUnauthorizedClient("public clients cannot use the client_credentials grant")
+	}
+
+	// Without an explicit scope the client receives every scope it is registered for
+	scopes := client.Scopes
+	if req.Scope != "" {
+		scopes = parseScope(req.Scope)
+		if !client.AllowsScopes(scopes) {
+			return nil, errInvalidScope("requested scope is not allowed for this client")
+		}
+	}
+	scope := strings.Join(scopes, " ")
+
+	claims, err := utils.ClientAccessTokenClaims(client.ClientID, scope)
+	if err != nil {
+		return nil, errServerError("failed to generate access token")
+	}
+	bindToKey(claims, req.DPoPKeyThumbprint)
+	accessToken, err := encodeAccessToken(uc.tokenRepo, claims)
+	if err != nil {
+		return nil, errServerError("failed to generate access token")
+	}
+
+	return &dto.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   accessTokenType(req.DPoPKeyThumbprint),
+		ExpiresIn:   int64(utils.JWTExpiration().Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// refreshAccessToken rotates the refresh token: the client receives a new one in the same
+// family and the presented token can no longer be used.
+func (uc *OAuthUseCase) refreshAccessToken(client *entity.OAuthClient, req dto.TokenRequest) (*dto.TokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, errInvalidRequest("refresh_token is required")
+	}
+
+	refreshToken, err := findRefreshToken(uc.tokenRepo, req.RefreshToken, client.ClientID, req.DPoPKeyThumbprint)
+	if err != nil {
+		return nil, err
+	}
+
+	// A refresh request may narrow the original scope but never widen it
+	scope := refreshToken.Scope
+	if req.Scope != "" {
+		if !scopesCovered(parseScope(refreshToken.Scope), parseScope(req.Scope)) {
+			return nil, errInvalidScope("requested scope exceeds the original grant")
+		}
+		scope = strings.Join(parseScope(req.Scope), " ")
+	}
+
+	// Everything that can fail is checked first, so a failed request leaves the token usable
+	user, err := uc.userRepo.FindByID(refreshToken.UserID)
+	if err != nil {
+		return nil, errInvalidGrant("resource owner no longer exists")
+	}
+
+	if err := rotateRefreshToken(uc.tokenRepo, req.RefreshToken, refreshToken); err != nil {
+		return nil, err
+	}
+
+	resp, err := uc.issueAccessToken(client, user, userGrant{
+		userID:   refreshToken.UserID,
+		scope:    scope,
+		authTime: refreshToken.AuthTime,
+		jkt:      req.DPoPKeyThumbprint,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The new refresh token keeps the original scope (RFC 6749 section 6)
+	resp.RefreshToken, err = issueRefreshToken(uc.tokenRepo, &entity.RefreshToken{
+		FamilyID: refreshToken.FamilyID,
+		ClientID: client.ClientID,
+		UserID:   refreshToken.UserID,
+		Scope:    refreshToken.Scope,
+		AuthTime: refreshToken.AuthTime,
+		JKT:      refreshToken.JKT,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// userGrant is what a user authorized for a client, carried into the tokens issued for it.
+// jkt is the thumbprint of the DPoP key the tokens are bound to, if the client sent a proof.
+type userGrant struct {
+	userID   uint
+	scope    string
+	nonce    string
+	authTime int64
+	jkt      string
+}
+
+func (uc *OAuthUseCase) issueTokens(client *entity.OAuthClient, grant userGrant) (*dto.TokenResponse, error) {
+	user, err := uc.userRepo.FindByID(grant.userID)
+	if err != nil {
+		return nil, errInvalidGrant("resource owner no longer exists")
+	}
+
+	resp, err := uc.issueAccessToken(client, user, grant)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.AllowsGrantType(GrantTypeRefreshToken) {
+		return resp, nil
+	}
+
+	resp.RefreshToken, err = issueRefreshToken(uc.tokenRepo, &entity.RefreshToken{
+		ClientID: client.ClientID,
+		UserID:   grant.userID,
+		Scope:    grant.scope,
+		AuthTime: grant.authTime,
+		JKT:      grant.jkt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// issueAccessToken issues the access token, and an ID token when the openid scope was granted.
+func (uc *OAuthUseCase) issueAccessToken(client *entity.OAuthClient, user *entity.User, grant userGrant) (*dto.TokenResponse, error) {
+	accessToken, err := uc.generateClientAccessToken(client, grant)
+	if err != nil {
+		return nil, errServerError("failed to generate access token")
+	}
+
+	resp := &dto.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   accessTokenType(grant.jkt),
+		ExpiresIn:   int64(utils.JWTExpiration().Seconds()),
+		Scope:       grant.scope,
+	}
+
+	if containsString(parseScope(grant.scope), ScopeOpenID) {
+		resp.IDToken, err = generateIDToken(client, user, grant, accessToken)
+		if err != nil {
+			return nil, errServerError("failed to generate id token")
+		}
+	}
+
+	return resp, nil
+}
+
+func (uc *OAuthUseCase) generateClientAccessToken(client *entity.OAuthClient, grant userGrant) (string, error) {
+	claims, err := utils.UserAccessTokenClaims(grant.userID, jwt.MapClaims{
+		"client_id": client.ClientID,
+		"scope":     grant.scope,
+	})
+	if err != nil {
+		return "", err
+	}
+	bindToKey(claims, grant.jkt)
+	return encodeAccessToken(uc.tokenRepo, claims)
+}
+
+func verifyCodeChallenge(verifier, challenge string) bool {
+	// RFC 7636 section 4.1: the verifier is 43 to 128 characters long
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	computed := oauth2.S256ChallengeFromVerifier(verifier)
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func parseScope(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// scopesCovered reports whether every requested scope is part of the granted set.
+func scopesCovered(granted, requested []string) bool {
+	set := make(map[string]bool, len(granted))
+	for _, scope := range granted {
+		set[scope] = true
+	}
+	for _, scope := range requested {
+		if !set[scope] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+const (
+	requestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+	pushedAuthorizationRequestTTL = 90 * time.Second
+)
+
+// PushAuthorizationRequest validates and stores the authorization request of an authenticated
+// client and returns the request_uri it sends the user to the authorization endpoint with (RFC 9126).
+func (uc *OAuthUseCase) PushAuthorizationRequest(client *entity.OAuthClient, req dto.AuthorizeRequest) (*dto.PushedAuthorizationResponse, error) {
+	if req.RequestURI != "" {
+		return nil, errInvalidRequest("request_uri cannot be pushed")
+	}
+	if req.ClientID != "" && req.ClientID != client.ClientID {
+		return nil, errInvalidRequest("client_id does not match the authenticated client")
+	}
+
+	if req.Request != "" {
+		var err error
+		req, err = requestObjectParams(client, req.Request)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.RedirectURI == "" || !client.HasRedirectURI(req.RedirectURI) {
+		return nil, errInvalidRequest("redirect_uri is not registered for this client")
+	}
+	if err := validateAuthorizeRequest(client, req); err != nil {
+		return nil, err
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, errServerError("failed to generate request_uri")
+	}
+	requestURI := requestURIPrefix + token
+
+	if err := uc.tokenRepo.SavePushedAuthorizationRequest(requestURI, &entity.PushedAuthorizationRequest{
+		ClientID:            client.ClientID,
+		ResponseType:        req.ResponseType,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		State:               req.State,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
+		Prompt:              req.Prompt,
+		ExpiresAt:           time.Now().Add(pushedAuthorizationRequestTTL),
+	}); err != nil {
+		return nil, errServerError("failed to store authorization request")
+	}
+
+	return &dto.PushedAuthorizationResponse{
+		RequestURI: requestURI,
+		ExpiresIn:  int64(pushedAuthorizationRequestTTL.Seconds()),
+	}, nil
+}
+
+// ResolveAuthorizeRequest returns the parameters an authorization request stands for: those
+// pushed under its request_uri, those of its signed request object, or its query parameters.
+// Clients that require PAR must use a request_uri. Errors must be shown to the user, not redirected.
+func (uc *OAuthUseCase) ResolveAuthorizeRequest(req dto.AuthorizeRequest) (dto.AuthorizeRequest, error) {
+	if req.ClientID == "" {
+		return dto.AuthorizeRequest{}, errInvalidRequest("client_id is required")
+	}
+	client, err := uc.clientRepo.FindByClientID(req.ClientID)
+	if err != nil {
+		return dto.AuthorizeRequest{}, errInvalidRequest("client_id is invalid")
+	}
+
+	if req.Request != "" && req.RequestURI != "" {
+		return dto.AuthorizeRequest{}, errInvalidRequest("request and request_uri cannot be used together")
+	}
+	if req.RequestURI != "" {
+		return uc.consumePushedAuthorizationRequest(client, req.RequestURI)
+	}
+	if client.RequirePushedAuthorizationRequests {
+		return dto.AuthorizeRequest{}, errInvalidRequest("client must use pushed authorization requests")
+	}
+	if req.Request != "" {
+		return requestObjectParams(client, req.Request)
+	}
+	return req, nil
+}
+
+func (uc *OAuthUseCase) consumePushedAuthorizationRequest(client *entity.OAuthClient, requestURI string) (dto.AuthorizeRequest, error) {
+	if !strings.HasPrefix(requestURI, requestURIPrefix) {
+		return dto.AuthorizeRequest{}, errInvalidRequestURI("request_uri was not issued by this server")
+	}
+
+	pushed, err := uc.tokenRepo.ConsumePushedAuthorizationRequest(requestURI)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return dto.AuthorizeRequest{}, errInvalidRequestURI("request_uri is invalid or expired")
+		}
+		return dto.AuthorizeRequest{}, errServerError("failed to read authorization request")
+	}
+	if pushed.ClientID != client.ClientID {
+		return dto.AuthorizeRequest{}, errInvalidRequestURI("request_uri was issued to another client")
+	}
+	if time.Now().After(pushed.ExpiresAt) {
+		return dto.AuthorizeRequest{}, errInvalidRequestURI("request_uri is invalid or expired")
+	}
+
+	return dto.AuthorizeRequest{
+		ResponseType:        pushed.ResponseType,
+		ClientID:            pushed.ClientID,
+		RedirectURI:         pushed.RedirectURI,
+		Scope:               pushed.Scope,
+		State:               pushed.State,
+		CodeChallenge:       pushed.CodeChallenge,
+		CodeChallengeMethod: pushed.CodeChallengeMethod,
+		Nonce:               pushed.Nonce,
+		Prompt:              pushed.Prompt,
+	}, nil
+}
+
+// requestObjectParams verifies a signed request object (RFC 9101) against the client's JWKS.
+// Only the parameters inside the object are used, never those sent next to it.
+func requestObjectParams(client *entity.OAuthClient, request string) (dto.AuthorizeRequest, error) {
+	if client.JWKS == "" {
+		return dto.AuthorizeRequest{}, errInvalidRequestObject("client has no registered jwks")
+	}
+	jwks, err := parseClientJWKS(client.JWKS)
+	if err != nil {
+		return dto.AuthorizeRequest{}, errServerError("registered jwks is invalid")
+	}
+
+	claims, err := utils.ParseRequestObject(request, jwks, client.ClientID)
+	if err != nil {
+		return dto.AuthorizeRequest{}, errInvalidRequestObject("request object is invalid")
+	}
+
+	param := func(name string) string {
+		value, _ := claims[name].(string)
+		return value
+	}
+	return dto.AuthorizeRequest{
+		ResponseType:        param("response_type"),
+		ClientID:            client.ClientID,
+		RedirectURI:         param("redirect_uri"),
+		Scope:               param("scope"),
+		State:               param("state"),
+		CodeChallenge:       param("code_challenge"),
+		CodeChallengeMethod: param("code_challenge_method"),
+		Nonce:               param("nonce"),
+		Prompt:              param("prompt"),
+	}, nil
+}
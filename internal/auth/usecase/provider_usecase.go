@@ -0,0 +1,243 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+const (
+	// LoginFlowTTL is how long the user has to log in at the provider.
+	LoginFlowTTL = 10 * time.Minute
+
+	loginCodeTTL = time.Minute
+)
+
+var (
+	ErrProviderNotFound = errors.New("identity provider not found")
+	ErrInvalidLoginFlow = errors.New("login is invalid, expired or already completed")
+	ErrInvalidReturnTo  = errors.New("return_to is not allowed")
+	ErrInvalidLoginCode = errors.New("login code is invalid or expired")
+	ErrAccountExists    = errors.New("an account with this email already exists with another provider")
+)
+
+// StartProviderLogin starts a login at the named identity provider with a fresh state, nonce
+// and PKCE verifier, and returns the provider's login page and the state. With returnTo, the
+// login ends with a redirect there instead of returning tokens to the browser: to a frontend
+// with a login code, or to the authorization endpoint with a browser session.
+func (uc *AuthUseCase) StartProviderLogin(name, returnTo string) (string, string, error) {
+	p, ok := uc.providers.Get(name)
+	if !ok {
+		return "", "", ErrProviderNotFound
+	}
+	if returnTo != "" && !allowedReturnTo(returnTo) && !IsAuthorizationEndpointURL(returnTo) {
+		return "", "", ErrInvalidReturnTo
+	}
+
+	var secrets [3]string
+	for i := range secrets {
+		secret, err := utils.GenerateRandomToken(32)
+		if err != nil {
+			return "", "", err
+		}
+		secrets[i] = secret
+	}
+	state, nonce, codeVerifier := secrets[0], secrets[1], secrets[2]
+
+	url, err := p.AuthCodeURL(state, nonce, codeVerifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	flow := &entity.LoginFlow{
+		Provider:     p.Name(),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ReturnTo:     returnTo,
+		ExpiresAt:    time.Now().Add(LoginFlowTTL),
+	}
+	if err := uc.tokenRepo.SaveLoginFlow(state, flow); err != nil {
+		return "", "", fmt.Errorf("failed to store login: %w", err)
+	}
+
+	return url, state, nil
+}
+
+// ConsumeLoginFlow ends the login identified by state at the named provider. It is consumed
+// before the callback does anything else, so a replayed callback always fails.
+func (uc *AuthUseCase) ConsumeLoginFlow(name, state string) (*entity.LoginFlow, error) {
+	p, ok := uc.providers.Get(name)
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+
+	flow, err := uc.tokenRepo.ConsumeLoginFlow(state)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return nil, ErrInvalidLoginFlow
+		}
+		return nil, fmt.Errorf("failed to read login: %w", err)
+	}
+	if flow.Provider != p.Name() || time.Now().After(flow.ExpiresAt) {
+		return nil, ErrInvalidLoginFlow
+	}
+	return flow, nil
+}
+
+// HandleProviderCallback logs in the user the provider returned the authorization code for.
+func (uc *AuthUseCase) HandleProviderCallback(flow *entity.LoginFlow, code string, clientInfo ClientInfo) (string, string, *dto.ProviderUserResponse, error) {
+	user, err := uc.authenticateWithProvider(flow, code)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	accessToken, refreshToken, err := uc.startSession(user.ID, clientInfo)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	return accessToken, refreshToken, providerUserResponse(user), nil
+}
+
+// IssueLoginCode authenticates the user like HandleProviderCallback, but returns the flow's
+// return_to URL with a one-time login_code instead of tokens, so they never appear in a URL.
+func (uc *AuthUseCase) IssueLoginCode(flow *entity.LoginFlow, code string) (string, error) {
+	user, err := uc.authenticateWithProvider(flow, code)
+	if err != nil {
+		return "", err
+	}
+
+	loginCode, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := uc.tokenRepo.SaveLoginCode(loginCode, &entity.LoginCode{
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(loginCodeTTL),
+	}); err != nil {
+		return "", fmt.Errorf("failed to store login code: %w", err)
+	}
+
+	return ReturnToURL(flow.ReturnTo, "login_code", loginCode), nil
+}
+
+// StartProviderBrowserSession authenticates the user like HandleProviderCallback, but starts
+// a browser session for a login that returns to the authorization endpoint.
+func (uc *AuthUseCase) StartProviderBrowserSession(flow *entity.LoginFlow, code string, clientInfo ClientInfo) (string, error) {
+	user, err := uc.authenticateWithProvider(flow, code)
+	if err != nil {
+		return "", err
+	}
+	return uc.StartBrowserSession(user.ID, clientInfo)
+}
+
+// RedeemLoginCode starts the session of a login code. The session belongs to the frontend
+// redeeming the code, including its DPoP key, rather than to the browser of the callback.
+func (uc *AuthUseCase) RedeemLoginCode(code string, clientInfo ClientInfo) (string, string, *dto.ProviderUserResponse, error) {
+	loginCode, err := uc.tokenRepo.ConsumeLoginCode(code)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return "", "", nil, ErrInvalidLoginCode
+		}
+		return "", "", nil, fmt.Errorf("failed to read login code: %w", err)
+	}
+	if time.Now().After(loginCode.ExpiresAt) {
+		return "", "", nil, ErrInvalidLoginCode
+	}
+
+	user, err := uc.userRepo.FindByID(loginCode.UserID)
+	if err != nil {
+		return "", "", nil, ErrInvalidLoginCode
+	}
+
+	accessToken, refreshToken, err := uc.startSession(user.ID, clientInfo)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	return accessToken, refreshToken, providerUserResponse(user), nil
+}
+
+// authenticateWithProvider redeems the authorization code and returns the user it identifies,
+// creating the account on the first login.
+func (uc *AuthUseCase) authenticateWithProvider(flow *entity.LoginFlow, code string) (*entity.User, error) {
+	p, ok := uc.providers.Get(flow.Provider)
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+
+	ctx := context.Background()
+	token, err := p.Exchange(ctx, code, flow.CodeVerifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange token: %w", err)
+	}
+
+	identity, err := p.Identity(ctx, token, flow.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user info: %w", err)
+	}
+
+	user, err := uc.userRepo.FindOrCreateUserByProvider(p.Name(), identity.Email, identity.ProviderID, identity.Name, identity.EmailVerified)
+	if err != nil {
+		if errors.Is(err, repository.ErrEmailInUse) {
+			return nil, ErrAccountExists
+		}
+		return nil, fmt.Errorf("failed to create/update user: %w", err)
+	}
+	return user, nil
+}
+
+// ReturnToURL adds a query parameter to a return_to URL, keeping the ones it already has.
+func ReturnToURL(returnTo, key, value string) string {
+	parsed, err := url.Parse(returnTo)
+	if err != nil {
+		return returnTo
+	}
+	query := parsed.Query()
+	query.Set(key, value)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// allowedReturnTo matches returnTo against LOGIN_RETURN_TO_ALLOWLIST, a comma separated list
+// of URLs. The scheme and host must be equal and the path must be within the listed path.
+func allowedReturnTo(returnTo string) bool {
+	target, err := url.Parse(returnTo)
+	if err != nil || !target.IsAbs() || target.User != nil {
+		return false
+	}
+	// Browsers resolve dot segments, so compare the path they will actually request
+	targetPath := path.Clean("/" + target.Path)
+
+	for _, entry := range strings.Split(config.GetEnv("LOGIN_RETURN_TO_ALLOWLIST"), ",") {
+		allowed, err := url.Parse(strings.TrimSpace(entry))
+		if err != nil || !allowed.IsAbs() {
+			continue
+		}
+		if target.Scheme != allowed.Scheme || !strings.EqualFold(target.Host, allowed.Host) {
+			continue
+		}
+		prefix := strings.TrimSuffix(allowed.Path, "/")
+		if targetPath == prefix || strings.HasPrefix(targetPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func providerUserResponse(user *entity.User) *dto.ProviderUserResponse {
+	return &dto.ProviderUserResponse{
+		Email: user.Email,
+		Name:  user.Name,
+	}
+}
@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+// issueRefreshToken stores a new refresh token for the record, starting a new token family
+// unless the record continues an existing one.
+func issueRefreshToken(tokenRepo repository.TokenRepository, refreshToken *entity.RefreshToken) (string, error) {
+	newFamily := refreshToken.FamilyID == ""
+	if newFamily {
+		familyID, err := utils.GenerateRandomToken(16)
+		if err != nil {
+			return "", errServerError("failed to generate refresh token family")
+		}
+		refreshToken.FamilyID = familyID
+	}
+
+	token, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return "", errServerError("failed to generate refresh token")
+	}
+
+	refreshToken.IssuedAt = time.Now()
+	refreshToken.ExpiresAt = refreshToken.IssuedAt.Add(utils.RefreshTokenExpiration())
+	if err := tokenRepo.SaveRefreshToken(token, refreshToken, newFamily); err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			// The family was revoked while the token was being rotated
+			return "", errInvalidGrant("refresh token is invalid or expired")
+		}
+		return "", errServerError("failed to store refresh token")
+	}
+	return token, nil
+}
+
+// findRefreshToken returns the refresh token if it was issued to clientID, which is empty
+// for first-party logins. A token bound to a DPoP key needs a proof made with that key, jkt.
+func findRefreshToken(tokenRepo repository.TokenRepository, token, clientID, jkt string) (*entity.RefreshToken, error) {
+	refreshToken, err := tokenRepo.FindRefreshToken(token)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return nil, errInvalidGrant("refresh token is invalid or expired")
+		}
+		return nil, errServerError("failed to read refresh token")
+	}
+
+	if refreshToken.ClientID != clientID {
+		return nil, errInvalidGrant("refresh token was issued to another client")
+	}
+	if refreshToken.JKT != "" && refreshToken.JKT != jkt {
+		return nil, errInvalidGrant("refresh token is bound to another DPoP key")
+	}
+	return refreshToken, nil
+}
+
+// errRefreshTokenReused is returned by rotateRefreshToken when it revoked the family of a reused token.
+var errRefreshTokenReused = errInvalidGrant("refresh token is invalid or expired")
+
+// rotateRefreshToken invalidates a refresh token that is being exchanged for a new one.
+// Presenting a token that was already rotated means it was copied, so the whole family is
+// revoked: either the attacker or the legitimate client holds the latest token.
+func rotateRefreshToken(tokenRepo repository.TokenRepository, token string, refreshToken *entity.RefreshToken) error {
+	if !refreshToken.Rotated {
+		rotated, err := tokenRepo.MarkRefreshTokenRotated(token, refreshToken)
+		if err != nil {
+			return errServerError("failed to rotate refresh token")
+		}
+		if rotated {
+			return nil
+		}
+	}
+
+	if err := tokenRepo.RevokeRefreshTokenFamily(refreshToken.FamilyID); err != nil {
+		return errServerError("failed to revoke refresh token family")
+	}
+	log.Printf("security event: refresh token reuse detected, family %s of user %d (client %q) revoked",
+		refreshToken.FamilyID, refreshToken.UserID, refreshToken.ClientID)
+	return errRefreshTokenReused
+}
@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+)
+
+// Revoke invalidates a single access or refresh token issued to the calling client (RFC 7009).
+// Unknown tokens and tokens of other clients are ignored so the endpoint does not reveal them.
+func (uc *OAuthUseCase) Revoke(client *entity.OAuthClient, req dto.RevocationRequest) error {
+	revokers := []func(*entity.OAuthClient, string) (bool, error){uc.revokeAccessToken, uc.revokeRefreshToken}
+	if req.TokenTypeHint == TokenTypeHintRefreshToken {
+		revokers[0], revokers[1] = revokers[1], revokers[0]
+	}
+
+	for _, revoke := range revokers {
+		found, err := revoke(client, req.Token)
+		if err != nil {
+			return err
+		}
+		if found {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (uc *OAuthUseCase) revokeAccessToken(client *entity.OAuthClient, token string) (bool, error) {
+	claims, err := ParseAccessToken(uc.tokenRepo, token, "")
+	if err != nil {
+		return false, nil
+	}
+
+	if clientID, _ := claims["client_id"].(string); clientID != client.ClientID {
+		return false, nil
+	}
+
+	if !isJWT(token) {
+		if err := uc.tokenRepo.DeleteAccessToken(token); err != nil {
+			return false, errServerError("failed to revoke access token")
+		}
+	}
+	jti, _ := claims["jti"].(string)
+	if err := uc.tokenRepo.RevokeAccessToken(jti, time.Unix(int64Claim(claims, "exp"), 0)); err != nil {
+		return false, errServerError("failed to revoke access token")
+	}
+	return true, nil
+}
+
+// revokeRefreshToken revokes the token's whole family, so tokens it was rotated into stop working too.
+func (uc *OAuthUseCase) revokeRefreshToken(client *entity.OAuthClient, token string) (bool, error) {
+	refreshToken, err := uc.tokenRepo.FindRefreshToken(token)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			return false, nil
+		}
+		return false, errServerError("failed to read refresh token")
+	}
+
+	if refreshToken.ClientID != client.ClientID {
+		return false, nil
+	}
+
+	if err := uc.tokenRepo.RevokeRefreshTokenFamily(refreshToken.FamilyID); err != nil {
+		return false, errServerError("failed to revoke refresh token")
+	}
+	return true, nil
+}
+
+// isAccessTokenRevoked checks the token's jti, its session and the revocation watermark of its user.
+func (uc *OAuthUseCase) isAccessTokenRevoked(claims jwt.MapClaims) (bool, error) {
+	jti, _ := claims["jti"].(string)
+	sessionID, _ := claims["sid"].(string)
+	userID, _ := claims["user_id"].(float64)
+	return uc.tokenRepo.IsAccessTokenRevoked(jti, sessionID, uint(userID), time.Unix(int64Claim(claims, "iat"), 0))
+}
@@ -0,0 +1,236 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+// SessionCookieName is the cookie that keeps the resource owner logged in at the authorization
+// endpoint, where browsers arrive through a client's redirect without an access token.
+const SessionCookieName = "oauth_session"
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// ClientInfo describes the device a session is started from. DPoPKeyThumbprint is set when the
+// login request carried a valid DPoP proof, binding the session's tokens to that key.
+type ClientInfo struct {
+	UserAgent         string
+	IPAddress         string
+	DPoPKeyThumbprint string
+}
+
+// ListSessions returns the user's active sessions, marking the one making the request.
+func (uc *AuthUseCase) ListSessions(userID uint, currentSessionID string) ([]dto.SessionResponse, error) {
+	sessions, err := uc.sessionRepo.FindByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]dto.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		resp = append(resp, dto.SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			Current:    session.ID == currentSessionID,
+		})
+	}
+	return resp, nil
+}
+
+// RevokeSession logs the session out: its refresh tokens and access tokens stop working immediately.
+func (uc *AuthUseCase) RevokeSession(userID uint, sessionID string) error {
+	session, err := uc.sessionRepo.Find(sessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+
+	return uc.endSession(session)
+}
+
+// RevokeOtherSessions logs the user out everywhere except in the current session.
+func (uc *AuthUseCase) RevokeOtherSessions(userID uint, currentSessionID string) error {
+	sessions, err := uc.sessionRepo.FindByUser(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == currentSessionID {
+			continue
+		}
+		if err := uc.endSession(session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeAllTokens invalidates every access and refresh token issued to the user so far and
+// ends all sessions, e.g. after a password change or when an account is compromised.
+func (uc *AuthUseCase) RevokeAllTokens(userID uint) error {
+	// The watermark must outlive the longest-lived token it rejects
+	ttl := utils.JWTExpiration()
+	if refreshTTL := utils.RefreshTokenExpiration(); refreshTTL > ttl {
+		ttl = refreshTTL
+	}
+	if err := uc.tokenRepo.RevokeUserTokensBefore(userID, time.Now(), ttl+utils.ClockSkew()); err != nil {
+		return err
+	}
+
+	return uc.RevokeOtherSessions(userID, "")
+}
+
+// StartBrowserSession starts a session for the browser itself instead of issuing tokens and
+// returns the secret of its session cookie. It is listed and revoked like any other session.
+func (uc *AuthUseCase) StartBrowserSession(userID uint, clientInfo ClientInfo) (string, error) {
+	sessionID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate session cookie: %w", err)
+	}
+
+	now := time.Now()
+	session := &entity.Session{
+		ID:         sessionID,
+		UserID:     userID,
+		UserAgent:  clientInfo.UserAgent,
+		IPAddress:  clientInfo.IPAddress,
+		AuthTime:   now.Unix(),
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(utils.RefreshTokenExpiration()),
+	}
+	if err := uc.sessionRepo.Save(session); err != nil {
+		return "", err
+	}
+	if err := uc.sessionRepo.SaveCookie(secret, session.ID, time.Until(session.ExpiresAt)); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// FindCookieSession returns the live session of a browser session cookie.
+func FindCookieSession(sessionRepo repository.SessionRepository, secret string) (*entity.Session, error) {
+	session, err := sessionRepo.FindByCookie(secret)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// LoginURI is the page where browsers without a session log in before authorizing a client.
+// The authorization endpoint answers login_required when it is not set.
+func LoginURI() string {
+	return config.GetEnv("OAUTH_LOGIN_URI")
+}
+
+// IsAuthorizationEndpointURL reports whether a login may return to returnTo with a browser
+// session: only the authorization endpoint of this server accepts one.
+func IsAuthorizationEndpointURL(returnTo string) bool {
+	target, err := url.Parse(returnTo)
+	if err != nil {
+		return false
+	}
+	issuer, err := url.Parse(utils.Issuer())
+	if err != nil {
+		return false
+	}
+	return target.Scheme == issuer.Scheme && strings.EqualFold(target.Host, issuer.Host) &&
+		target.User == nil && target.Path == issuer.Path+"/oauth/authorize"
+}
+
+func (uc *AuthUseCase) startSession(userID uint, clientInfo ClientInfo) (string, string, error) {
+	sessionID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	now := time.Now()
+	session := &entity.Session{
+		ID:         sessionID,
+		UserID:     userID,
+		UserAgent:  clientInfo.UserAgent,
+		IPAddress:  clientInfo.IPAddress,
+		JKT:        clientInfo.DPoPKeyThumbprint,
+		AuthTime:   now.Unix(),
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	return uc.issueSessionTokens(session)
+}
+
+// issueSessionTokens issues an access token bound to the session and the next refresh token
+// of its family, and extends the session to the lifetime of that refresh token.
+func (uc *AuthUseCase) issueSessionTokens(session *entity.Session) (string, string, error) {
+	claims, err := utils.UserAccessTokenClaims(session.UserID, jwt.MapClaims{
+		"sid":       session.ID,
+		"auth_time": session.AuthTime,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+	bindToKey(claims, session.JKT)
+	accessToken, err := encodeAccessToken(uc.tokenRepo, claims)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	record := &entity.RefreshToken{
+		FamilyID:  session.RefreshFamilyID,
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		AuthTime:  session.AuthTime,
+		JKT:       session.JKT,
+	}
+	refreshToken, err := issueRefreshToken(uc.tokenRepo, record)
+	if err != nil {
+		return "", "", err
+	}
+
+	session.RefreshFamilyID = record.FamilyID
+	session.ExpiresAt = record.ExpiresAt
+	if err := uc.sessionRepo.Save(session); err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+func (uc *AuthUseCase) endSession(session *entity.Session) error {
+	if err := uc.sessionRepo.Delete(session); err != nil {
+		return err
+	}
+	if err := uc.tokenRepo.RevokeRefreshTokenFamily(session.RefreshFamilyID); err != nil {
+		return err
+	}
+	// Access tokens of the session can live at most JWT_EXPIRATION after it ends
+	return uc.tokenRepo.RevokeSessionTokens(session.ID, utils.JWTExpiration()+utils.ClockSkew())
+}
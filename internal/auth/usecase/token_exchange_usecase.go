@@ -0,0 +1,219 @@
+package usecase
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/dto"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/entity"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
+)
+
+const (
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT         = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// exchangeToken swaps the user's subject_token for an access token targeted at the requested
+// audience, optionally with a narrower scope (RFC 8693). With an actor_token the new token is a
+// delegation token whose act claim names the actor; without one it simply stands for the user.
+func (uc *OAuthUseCase) exchangeToken(client *entity.OAuthClient, req dto.TokenRequest) (*dto.TokenResponse, error) {
+	if client.IsPublic() {
+		return nil, errUnauthorizedClient("public clients cannot use the token exchange grant")
+	}
+	if req.SubjectToken == "" {
+		return nil, errInvalidRequest("subject_token is required")
+	}
+	if !isAccessTokenType(req.SubjectTokenType) {
+		return nil, errInvalidRequest("subject_token_type must be " + TokenTypeAccessToken)
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != TokenTypeAccessToken {
+		return nil, errInvalidRequest("requested_token_type is not supported")
+	}
+
+	// Every audience, this server's own included, must be allowed for the client
+	audience := req.Audience
+	if audience == "" {
+		audience = utils.Audience()
+	}
+	if !client.AllowsTokenExchangeAudience(audience) {
+		return nil, errInvalidTarget("client is not allowed to exchange tokens for this audience")
+	}
+
+	subject, err := uc.parseExchangedToken(req.SubjectToken, "subject_token")
+	if err != nil {
+		return nil, err
+	}
+	userID, ok := subject["user_id"].(float64)
+	if !ok {
+		return nil, errInvalidGrant("subject_token was not issued to a user")
+	}
+	if _, err := uc.userRepo.FindByID(uint(userID)); err != nil {
+		return nil, errInvalidGrant("resource owner no longer exists")
+	}
+
+	// A bound subject token may only be exchanged by the holder of its key, and stays bound
+	if jkt := BoundKeyThumbprint(subject); jkt != "" && jkt != req.DPoPKeyThumbprint {
+		return nil, errInvalidGrant("subject_token is bound to another DPoP key")
+	}
+
+	granted, err := uc.grantedScopes(client, uint(userID), subject)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := exchangedScope(client, granted, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	act, err := uc.actorClaim(client, req, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	extraClaims := jwt.MapClaims{
+		"client_id": client.ClientID,
+		"scope":     scope,
+		"aud":       audience,
+	}
+	// Keep the session and watermark checks of the subject token working for the new one
+	for _, name := range []string{"sid", "auth_time"} {
+		if value, ok := subject[name]; ok {
+			extraClaims[name] = value
+		}
+	}
+	if act != nil {
+		extraClaims["act"] = act
+	}
+
+	claims, err := utils.UserAccessTokenClaims(uint(userID), extraClaims)
+	if err != nil {
+		return nil, errServerError("failed to generate access token")
+	}
+	bindToKey(claims, req.DPoPKeyThumbprint)
+	// The exchanged token never outlives the subject token
+	exp := claims["exp"].(int64)
+	if subjectExp := int64Claim(subject, "exp"); subjectExp < exp {
+		exp = subjectExp
+		claims["exp"] = exp
+	}
+	// The subject token is accepted within the clock skew, but the new one must not be born expired
+	if exp <= time.Now().Unix() {
+		return nil, errInvalidGrant("subject_token has expired")
+	}
+
+	accessToken, err := encodeAccessToken(uc.tokenRepo, claims)
+	if err != nil {
+		return nil, errServerError("failed to generate access token")
+	}
+
+	if act != nil {
+		log.Printf("Token exchange: %v acts for user %d through client %s, audience %s", act["sub"], uint(userID), client.ClientID, audience)
+	}
+
+	return &dto.TokenResponse{
+		AccessToken:     accessToken,
+		IssuedTokenType: TokenTypeAccessToken,
+		TokenType:       accessTokenType(req.DPoPKeyThumbprint),
+		ExpiresIn:       exp - time.Now().Unix(),
+		Scope:           scope,
+	}, nil
+}
+
+// actorClaim builds the act claim of a delegation token (RFC 8693 section 4.1). The actor_token
+// must have been issued to the calling client. Actors of the subject token stay nested below it.
+func (uc *OAuthUseCase) actorClaim(client *entity.OAuthClient, req dto.TokenRequest, subject jwt.MapClaims) (map[string]interface{}, error) {
+	priorAct, _ := subject["act"].(map[string]interface{})
+	if req.ActorToken == "" {
+		if req.ActorTokenType != "" {
+			return nil, errInvalidRequest("actor_token_type requires actor_token")
+		}
+		return priorAct, nil
+	}
+	if !isAccessTokenType(req.ActorTokenType) {
+		return nil, errInvalidRequest("actor_token_type must be " + TokenTypeAccessToken)
+	}
+
+	actor, err := uc.parseExchangedToken(req.ActorToken, "actor_token")
+	if err != nil {
+		return nil, err
+	}
+	if clientID, _ := actor["client_id"].(string); clientID != client.ClientID {
+		return nil, errInvalidGrant("actor_token was issued to another client")
+	}
+
+	act := map[string]interface{}{"sub": actor["sub"]}
+	if priorAct != nil {
+		act["act"] = priorAct
+	}
+	return act, nil
+}
+
+// parseExchangedToken accepts any live access token of this server, whatever its audience,
+// so exchanged tokens can be exchanged again. param names the request parameter in errors.
+func (uc *OAuthUseCase) parseExchangedToken(token, param string) (jwt.MapClaims, error) {
+	claims, err := ParseAccessToken(uc.tokenRepo, token, "")
+	if err != nil {
+		return nil, errInvalidGrant(param + " is invalid")
+	}
+
+	revoked, err := uc.isAccessTokenRevoked(claims)
+	if err != nil {
+		return nil, errServerError("failed to check token revocation")
+	}
+	if revoked {
+		return nil, errInvalidGrant(param + " has been revoked")
+	}
+	return claims, nil
+}
+
+// grantedScopes returns the scopes the subject token grants. Tokens without a scope claim come
+// from a first-party login, so they grant only what the user consented to for this client.
+func (uc *OAuthUseCase) grantedScopes(client *entity.OAuthClient, userID uint, subject jwt.MapClaims) ([]string, error) {
+	if scope, ok := subject["scope"].(string); ok {
+		return parseScope(scope), nil
+	}
+
+	consent, err := uc.consentRepo.Find(userID, client.ClientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrConsentNotFound) {
+			return nil, nil
+		}
+		return nil, errServerError("failed to read consent")
+	}
+	return consent.Scopes, nil
+}
+
+// exchangedScope narrows the granted scopes to the requested ones and to those the client is
+// registered for.
+func exchangedScope(client *entity.OAuthClient, granted []string, requested string) (string, error) {
+
+	if requested == "" {
+		var scopes []string
+		for _, scope := range granted {
+			if client.AllowsScopes([]string{scope}) {
+				scopes = append(scopes, scope)
+			}
+		}
+		return strings.Join(scopes, " "), nil
+	}
+
+	scopes := parseScope(requested)
+	if !scopesCovered(granted, scopes) {
+		return "", errInvalidScope("requested scope exceeds the subject token")
+	}
+	if !client.AllowsScopes(scopes) {
+		return "", errInvalidScope("requested scope is not allowed for this client")
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+func isAccessTokenType(tokenType string) bool {
+	return tokenType == TokenTypeAccessToken || tokenType == TokenTypeJWT
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,3 +17,12 @@ func LoadEnv() {
 func GetEnv(key string) string {
 	return os.Getenv(key)
 }
+
+// GetEnvDuration parses the variable as a time.Duration, returning fallback when it is unset or invalid.
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
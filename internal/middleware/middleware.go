@@ -1,17 +1,28 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis"
-	"github.com/golang-jwt/jwt"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/usecase"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/utils"
 )
 
-func AuthMiddleware(redisClient *redis.Client) gin.HandlerFunc {
+// AuthMiddleware verifies the bearer token. Revocations are checked against the in-process
+// cache, falling back to Redis while the cache is out of sync. When both are unavailable,
+// TOKEN_REVOCATION_FAIL_MODE=open accepts the token and closed (the default) rejects it.
+// DPoP-bound tokens must use the DPoP scheme with a proof signed by the key they are bound to.
+func AuthMiddleware(redisClient *redis.Client, revocations *repository.RevocationCache) gin.HandlerFunc {
+	tokenRepo := repository.NewTokenRepository(redisClient)
+	failOpen := config.GetEnv("TOKEN_REVOCATION_FAIL_MODE") == "open"
+
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -21,39 +32,180 @@ func AuthMiddleware(redisClient *redis.Client) gin.HandlerFunc {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		isDPoP := false
+		if tokenString == authHeader {
+			tokenString = strings.TrimPrefix(authHeader, "DPoP ")
+			isDPoP = tokenString != authHeader
+		}
 		if tokenString == authHeader {
 			utils.SendResponse(c, http.StatusUnauthorized, "Invalid token format", nil, true)
 			c.Abort()
 			return
 		}
 
-		// Check if the token is blacklisted
-		_, err := redisClient.Get("blacklist:" + tokenString).Result()
-		if err == nil {
-			utils.SendResponse(c, http.StatusUnauthorized, "Token is blacklisted", nil, true)
+		// Verify the JWT signature against the key identified by its kid, or resolve the opaque token
+		claims, err := usecase.ParseAccessToken(*tokenRepo, tokenString, utils.Audience())
+		if errors.Is(err, utils.ErrTokenExpired) {
+			utils.SendResponse(c, http.StatusUnauthorized, "Token has expired", nil, true)
 			c.Abort()
 			return
 		}
-
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.GetEnv("JWT_SECRET")), nil
-		})
-		if err != nil || !token.Valid {
+		if err != nil {
 			utils.SendResponse(c, http.StatusUnauthorized, "Invalid token", nil, true)
 			c.Abort()
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
+		userIDClaim, isUserToken := claims["user_id"].(float64)
+		clientID, isClientToken := claims["client_id"].(string)
+		if !isUserToken && !isClientToken {
 			utils.SendResponse(c, http.StatusUnauthorized, "Invalid token claims", nil, true)
 			c.Abort()
 			return
 		}
-		userID := uint(claims["user_id"].(float64))
 
-		// Set the user ID in the Gin context
-		c.Set("userID", userID)
+		jkt := usecase.BoundKeyThumbprint(claims)
+		if jkt != "" || isDPoP {
+			if jkt == "" || !isDPoP {
+				utils.SendResponse(c, http.StatusUnauthorized, "Token must be sent with the DPoP scheme and a DPoP proof", nil, true)
+				c.Abort()
+				return
+			}
+
+			proofs := c.Request.Header.Values("DPoP")
+			if len(proofs) != 1 {
+				utils.SendResponse(c, http.StatusUnauthorized, "Exactly one DPoP proof is required", nil, true)
+				c.Abort()
+				return
+			}
+			thumbprint, err := usecase.VerifyDPoPProof(*tokenRepo, proofs[0], c.Request.Method, usecase.DPoPURI(c.Request.URL.Path), tokenString)
+			if err != nil && !errors.Is(err, utils.ErrInvalidDPoPProof) {
+				utils.SendResponse(c, http.StatusServiceUnavailable, "DPoP replay check is unavailable", nil, true)
+				c.Abort()
+				return
+			}
+			if err != nil || thumbprint != jkt {
+				utils.SendResponse(c, http.StatusUnauthorized, "Invalid DPoP proof", nil, true)
+				c.Abort()
+				return
+			}
+		}
+
+		// Check if the token, its session or every token of its user has been revoked
+		jti, _ := claims["jti"].(string)
+		sessionID, _ := claims["sid"].(string)
+		issuedAt, _ := claims["iat"].(float64)
+		revoked, err := revocations.IsAccessTokenRevoked(jti, sessionID, uint(userIDClaim), time.Unix(int64(issuedAt), 0))
+		if errors.Is(err, repository.ErrRevocationCacheStale) {
+			revoked, err = tokenRepo.IsAccessTokenRevoked(jti, sessionID, uint(userIDClaim), time.Unix(int64(issuedAt), 0))
+		}
+		if err != nil && !failOpen {
+			utils.SendResponse(c, http.StatusServiceUnavailable, "Token revocation status is unavailable", nil, true)
+			c.Abort()
+			return
+		}
+		if revoked {
+			utils.SendResponse(c, http.StatusUnauthorized, "Token has been revoked", nil, true)
+			c.Abort()
+			return
+		}
+
+		// Set the user ID in the Gin context, client_credentials tokens have none
+		if isUserToken {
+			c.Set("userID", uint(userIDClaim))
+		}
+		if isClientToken {
+			c.Set("clientID", clientID)
+		}
+		scope, _ := claims["scope"].(string)
+		c.Set("scopes", strings.Fields(scope))
+		if authTime, ok := claims["auth_time"].(float64); ok {
+			c.Set("authTime", int64(authTime))
+		}
+		if exp, ok := claims["exp"].(float64); ok {
+			c.Set("tokenExpiresAt", time.Unix(int64(exp), 0))
+		}
+		c.Set("tokenID", jti)
+		if sessionID != "" {
+			c.Set("sessionID", sessionID)
+		}
+		c.Next()
+	}
+}
+
+// ResourceOwnerMiddleware authenticates the user on the authorization endpoints: with the access
+// token when an Authorization header is sent, else with the browser session cookie. Requests
+// with neither continue without a userID, so the handler can send the browser to log in.
+func ResourceOwnerMiddleware(redisClient *redis.Client, revocations *repository.RevocationCache) gin.HandlerFunc {
+	authMiddleware := AuthMiddleware(redisClient, revocations)
+	sessionRepo := repository.NewSessionRepository(redisClient)
+
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			authMiddleware(c)
+			return
+		}
+
+		secret, err := c.Cookie(usecase.SessionCookieName)
+		if err == nil && secret != "" {
+			session, err := usecase.FindCookieSession(*sessionRepo, secret)
+			if err != nil && !errors.Is(err, usecase.ErrSessionNotFound) {
+				utils.SendResponse(c, http.StatusServiceUnavailable, "Session store is unavailable", nil, true)
+				c.Abort()
+				return
+			}
+			if session != nil {
+				c.Set("userID", session.UserID)
+				c.Set("authTime", session.AuthTime)
+				c.Set("sessionID", session.ID)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireScopes rejects tokens that were not granted every listed scope. It must run after AuthMiddleware.
+func RequireScopes(required ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := c.GetStringSlice("scopes")
+		for _, scope := range required {
+			if !containsScope(granted, scope) {
+				utils.SendResponse(c, http.StatusForbidden, "Insufficient scope", nil, true)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminMiddleware guards operational endpoints with the static ADMIN_API_TOKEN.
+// Admin routes are disabled when the token is not configured.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminToken := config.GetEnv("ADMIN_API_TOKEN")
+		if adminToken == "" {
+			utils.SendResponse(c, http.StatusForbidden, "Admin API is disabled", nil, true)
+			c.Abort()
+			return
+		}
+
+		token := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			utils.SendResponse(c, http.StatusUnauthorized, "Invalid admin token", nil, true)
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
@@ -4,24 +4,83 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/handler"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/repository"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/auth/usecase"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/middleware"
 )
 
-func SetupRoutes(router *gin.Engine, authHandler *handler.AuthHandler, redisClient *redis.Client) {
+func SetupRoutes(router *gin.Engine, authHandler *handler.AuthHandler, oauthHandler *handler.OAuthHandler, clientHandler *handler.ClientHandler, discoveryHandler *handler.DiscoveryHandler, keyHandler *handler.KeyHandler, redisClient *redis.Client, revocations *repository.RevocationCache) {
 	// Public routes (no authentication required)
 	public := router.Group("/api")
 	{
 		public.POST("/auth/register", authHandler.Register)
 		public.POST("/auth/login", authHandler.Login)
-		public.GET("/auth/login/google", authHandler.GoogleLogin)
-		public.GET("/auth/login/google/callback", authHandler.GoogleCallback)
+		public.POST("/auth/refresh", authHandler.RefreshToken)
+		public.POST("/auth/login/code", authHandler.RedeemLoginCode)
+		public.GET("/auth/login/:provider", authHandler.ProviderLogin)
+		public.GET("/auth/login/:provider/callback", authHandler.ProviderCallback)
 	}
 
 	// Protected routes (authentication required)
 	protected := router.Group("/api")
-	protected.Use(middleware.AuthMiddleware(redisClient))
+	protected.Use(middleware.AuthMiddleware(redisClient, revocations))
 	{
 		protected.POST("/auth/logout", authHandler.Logout)
-		protected.POST("/auth/refresh", authHandler.RefreshToken)
+		protected.GET("/auth/sessions", authHandler.ListSessions)
+		protected.DELETE("/auth/sessions", authHandler.RevokeOtherSessions)
+		protected.DELETE("/auth/sessions/:id", authHandler.RevokeSession)
+	}
+
+	// OpenID Connect discovery
+	wellKnown := router.Group("/.well-known")
+	{
+		wellKnown.GET("/openid-configuration", discoveryHandler.OpenIDConfiguration)
+		wellKnown.GET("/jwks.json", discoveryHandler.JWKS)
+	}
+
+	// OAuth 2.0 authorization server endpoints
+	oauth := router.Group("/oauth")
+	{
+		oauth.POST("/login", authHandler.BrowserLogin)
+		oauth.POST("/token", oauthHandler.Token)
+		oauth.POST("/par", oauthHandler.PushAuthorizationRequest)
+		oauth.POST("/device_authorization", oauthHandler.DeviceAuthorization)
+		oauth.POST("/introspect", oauthHandler.Introspect)
+		oauth.POST("/revoke", oauthHandler.Revoke)
+
+		// Dynamic client registration, authorized by the initial or registration access token
+		oauth.POST("/register", clientHandler.Register)
+		oauth.GET("/register/:client_id", clientHandler.GetClient)
+		oauth.PUT("/register/:client_id", clientHandler.UpdateClient)
+		oauth.DELETE("/register/:client_id", clientHandler.DeleteClient)
+	}
+
+	// The resource owner must be logged in to authorize a client, with a session cookie or an access token
+	oauthProtected := router.Group("/oauth")
+	oauthProtected.Use(middleware.ResourceOwnerMiddleware(redisClient, revocations))
+	{
+		oauthProtected.GET("/authorize", oauthHandler.Authorize)
+		oauthProtected.GET("/device", oauthHandler.DeviceVerification)
+		oauthProtected.POST("/device", oauthHandler.CompleteDeviceVerification)
+		oauthProtected.GET("/consent", oauthHandler.ConsentRequest)
+		oauthProtected.POST("/consent", oauthHandler.CompleteConsent)
+	}
+
+	// OpenID Connect UserInfo, accepts both GET and POST (OIDC Core section 5.3.1)
+	userInfo := router.Group("/userinfo")
+	userInfo.Use(middleware.AuthMiddleware(redisClient, revocations), middleware.RequireScopes(usecase.ScopeOpenID))
+	{
+		userInfo.GET("", oauthHandler.UserInfo)
+		userInfo.POST("", oauthHandler.UserInfo)
+	}
+
+	// Admin routes (ADMIN_API_TOKEN required)
+	admin := router.Group("/api/admin")
+	admin.Use(middleware.AdminMiddleware())
+	{
+		admin.GET("/keys", keyHandler.ListKeys)
+		admin.POST("/keys/rotate", keyHandler.RotateKey)
+		admin.POST("/keys/:kid/revoke", keyHandler.RevokeKey)
+		admin.POST("/users/:id/revoke-tokens", authHandler.RevokeUserTokens)
 	}
 }
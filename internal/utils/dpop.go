@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
+)
+
+const dpopProofType = "dpop+jwt"
+
+// ErrInvalidDPoPProof is wrapped by every error caused by the proof itself rather than by the server.
+var ErrInvalidDPoPProof = errors.New("invalid DPoP proof")
+
+// DPoPProof is a verified DPoP proof JWT (RFC 9449 section 4).
+type DPoPProof struct {
+	ID         string
+	Thumbprint string
+	IssuedAt   time.Time
+}
+
+// ParseDPoPProof verifies the proof's signature against the key embedded in its header and
+// checks that it was made for this request: htm and htu must match method and uri, and iat
+// must be recent. accessToken is the token the proof is presented with, empty at the token endpoint.
+func ParseDPoPProof(proof, method, uri, accessToken string) (*DPoPProof, error) {
+	var jwk JWK
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(proof, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["typ"] != dpopProofType {
+			return nil, errors.New("typ must be " + dpopProofType)
+		}
+		// Only asymmetric algorithms prove possession of a private key
+		if !containsAlgorithm(AsymmetricSigningAlgorithms, token.Method.Alg()) {
+			return nil, fmt.Errorf("unsupported signing method: %v", token.Header["alg"])
+		}
+
+		header, ok := token.Header["jwk"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("jwk header is required")
+		}
+		if _, private := header["d"]; private {
+			return nil, errors.New("jwk header must not contain a private key")
+		}
+		data, err := json.Marshal(header)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &jwk); err != nil {
+			return nil, err
+		}
+		return jwk.PublicKey()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDPoPProof, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid claims", ErrInvalidDPoPProof)
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, fmt.Errorf("%w: jti is required", ErrInvalidDPoPProof)
+	}
+	if htm, _ := claims["htm"].(string); htm != method {
+		return nil, fmt.Errorf("%w: htm does not match the request method", ErrInvalidDPoPProof)
+	}
+	if htu, _ := claims["htu"].(string); !sameHTU(htu, uri) {
+		return nil, fmt.Errorf("%w: htu does not match the request URI", ErrInvalidDPoPProof)
+	}
+
+	iat, ok := numericClaim(claims, "iat")
+	if !ok {
+		return nil, fmt.Errorf("%w: iat is required", ErrInvalidDPoPProof)
+	}
+	issuedAt := time.Unix(iat, 0)
+	now := time.Now()
+	if issuedAt.After(now.Add(ClockSkew())) || issuedAt.Before(now.Add(-DPoPProofMaxAge()-ClockSkew())) {
+		return nil, fmt.Errorf("%w: iat is not recent", ErrInvalidDPoPProof)
+	}
+
+	if accessToken != "" {
+		if ath, _ := claims["ath"].(string); ath != AccessTokenThumbprint(accessToken) {
+			return nil, fmt.Errorf("%w: ath does not match the access token", ErrInvalidDPoPProof)
+		}
+	}
+
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDPoPProof, err)
+	}
+
+	return &DPoPProof{ID: jti, Thumbprint: thumbprint, IssuedAt: issuedAt}, nil
+}
+
+// AccessTokenThumbprint is the ath claim of DPoP proofs: the base64url encoded SHA-256 of the token.
+func AccessTokenThumbprint(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// DPoPProofMaxAge is how old a proof may be, read from DPOP_PROOF_MAX_AGE.
+func DPoPProofMaxAge() time.Duration {
+	return config.GetEnvDuration("DPOP_PROOF_MAX_AGE", 5*time.Minute)
+}
+
+// sameHTU compares two URIs without their query and fragment (RFC 9449 section 4.3).
+func sameHTU(htu, uri string) bool {
+	a, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+	b, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host) && a.EscapedPath() == b.EscapedPath()
+}
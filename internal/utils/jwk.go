@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+// AsymmetricSigningAlgorithms lists the algorithms accepted for JWTs signed by clients, such as
+// DPoP proofs and request objects. Symmetric algorithms and none prove nothing about a key.
+var AsymmetricSigningAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// JWK is a public key in the JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewJWK(publicKey crypto.PublicKey) (JWK, error) {
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return JWK{
+			Kty: "EC",
+			Crv: k.Curve.Params().Name,
+			X:   base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, size))),
+			Y:   base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}, nil
+	default:
+		return JWK{}, errors.New("unsupported public key type")
+	}
+}
+
+// PublicKey converts the JWK back to the public key it describes.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.New("invalid RSA modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if publicKey.N.BitLen() < 2048 {
+			return nil, errors.New("RSA key is too short")
+		}
+		return publicKey, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("unsupported curve")
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, errors.New("invalid EC coordinates")
+		}
+		publicKey := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return publicKey, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+}
+
+// PublicJWKS returns the verification keys of the key set for publication on the JWKS endpoint.
+func PublicJWKS(ks *KeySet) (JWKS, error) {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.Keys() {
+		jwk, err := NewJWK(key.PublicKey())
+		if err != nil {
+			return JWKS{}, err
+		}
+		jwk.Use = "sig"
+		jwk.Kid = key.ID
+		jwk.Alg = key.Algorithm
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks, nil
+}
+
+// Thumbprint computes the RFC 7638 SHA-256 thumbprint over the required members of the key.
+func (k JWK) Thumbprint() (string, error) {
+	var members interface{}
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	default:
+		return "", errors.New("unsupported key type")
+	}
+
+	// Struct fields are declared in lexicographic order as the RFC requires
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// Key returns the signing key with the kid. A set with a single key also matches an empty kid.
+func (s JWKS) Key(kid string) (JWK, bool) {
+	if kid == "" && len(s.Keys) == 1 {
+		return s.Keys[0], true
+	}
+	for _, key := range s.Keys {
+		if kid != "" && key.Kid == kid && key.Use != "enc" {
+			return key, true
+		}
+	}
+	return JWK{}, false
+}
+
+func containsAlgorithm(algorithms []string, alg string) bool {
+	for _, a := range algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
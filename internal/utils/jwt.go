@@ -2,35 +2,103 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
 )
 
-func GenerateJWT(userID uint) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     JWTExpiration(),
+// UserAccessTokenClaims returns the claims of an access token issued to a user.
+func UserAccessTokenClaims(userID uint, extraClaims jwt.MapClaims) (jwt.MapClaims, error) {
+	claims, err := accessTokenClaims(strconv.FormatUint(uint64(userID), 10))
+	if err != nil {
+		return nil, err
+	}
+	claims["user_id"] = userID
+	for key, value := range extraClaims {
+		claims[key] = value
+	}
+	return claims, nil
+}
+
+// ClientAccessTokenClaims returns the claims of an access token whose subject is an OAuth client rather than a user.
+func ClientAccessTokenClaims(clientID, scope string) (jwt.MapClaims, error) {
+	claims, err := accessTokenClaims(clientID)
+	if err != nil {
+		return nil, err
+	}
+	claims["client_id"] = clientID
+	claims["scope"] = scope
+	return claims, nil
+}
+
+// accessTokenClaims returns the registered claims every access token carries (RFC 7519 section 4.1).
+func accessTokenClaims(subject string) (jwt.MapClaims, error) {
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub": subject,
+		"iss": Issuer(),
+		"aud": Audience(),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(JWTExpiration()).Unix(),
+		"jti": jti,
+	}, nil
+}
+
+// ErrNoSigningKey is returned while the key set has no active key, e.g. right after it was revoked.
+var ErrNoSigningKey = errors.New("no active signing key")
+
+// SignJWT signs the claims with the active key and sets its kid in the header.
+func SignJWT(claims jwt.MapClaims) (string, error) {
+	key := CurrentKeySet().Active()
+	if key == nil {
+		return "", ErrNoSigningKey
 	}
 
 	// Create the token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(key.Method(), claims)
+	token.Header["kid"] = key.ID
 
-	secret := config.GetEnv("JWT_SECRET")
-	return token.SignedString([]byte(secret))
+	return token.SignedString(key.PrivateKey)
 }
 
+// ParseJWT verifies the signature of an access token and validates its registered claims.
 func ParseJWT(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	return ParseJWTForAudience(tokenString, Audience())
+}
+
+// ParseJWTForAudience is ParseJWT for tokens issued to another audience. An empty
+// audience accepts any audience, for callers such as introspection that serve them all.
+func ParseJWTForAudience(tokenString, audience string) (jwt.MapClaims, error) {
+	// Time-based claims are validated below, with clock skew tolerance
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := CurrentKeySet().Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key: %q", kid)
+		}
+
+		// Validate the signing method against the key, never against the token header alone
+		if token.Method.Alg() != key.Algorithm {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(config.GetEnv("JWT_SECRET")), nil
+		return key.PublicKey(), nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -45,9 +113,115 @@ func ParseJWT(tokenString string) (jwt.MapClaims, error) {
 		return nil, errors.New("invalid token claims")
 	}
 
+	if err := ValidateClaims(claims, audience); err != nil {
+		return nil, err
+	}
+
 	return claims, nil
 }
 
+var (
+	ErrTokenExpired     = errors.New("token has expired")
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("token issuer is invalid")
+	ErrInvalidAudience  = errors.New("token audience is invalid")
+)
+
+// ValidateClaims checks the registered claims of an access token: exp, nbf and iat with
+// clock skew tolerance, the issuer of this server and, unless empty, the audience.
+func ValidateClaims(claims jwt.MapClaims, audience string) error {
+	if err := validateTimeClaims(claims); err != nil {
+		return err
+	}
+
+	if iss, _ := claims["iss"].(string); iss != Issuer() {
+		return ErrInvalidIssuer
+	}
+	if audience != "" && !hasAudience(claims["aud"], audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// validateTimeClaims requires exp and checks exp, nbf and iat with clock skew tolerance.
+func validateTimeClaims(claims jwt.MapClaims) error {
+	now := time.Now()
+	skew := ClockSkew()
+
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return errors.New("token has no expiration")
+	}
+	if !now.Before(time.Unix(exp, 0).Add(skew)) {
+		return ErrTokenExpired
+	}
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Add(skew).Before(time.Unix(nbf, 0)) {
+		return ErrTokenNotYetValid
+	}
+	if iat, ok := numericClaim(claims, "iat"); ok && now.Add(skew).Before(time.Unix(iat, 0)) {
+		return ErrTokenNotYetValid
+	}
+	return nil
+}
+
+func numericClaim(claims jwt.MapClaims, name string) (int64, bool) {
+	switch value := claims[name].(type) {
+	case float64:
+		return int64(value), true
+	case json.Number:
+		n, err := value.Int64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// hasAudience accepts aud as a single string or an array of strings (RFC 7519 section 4.1.3).
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, value := range aud {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Issuer returns the public base URL of this server, used as the OAuth/OpenID Connect issuer.
+func Issuer() string {
+	return strings.TrimSuffix(config.GetEnv("OAUTH_ISSUER"), "/")
+}
+
+// Audience is the aud claim of access tokens, taken from JWT_AUDIENCE and defaulting to the issuer.
+func Audience() string {
+	if audience := config.GetEnv("JWT_AUDIENCE"); audience != "" {
+		return audience
+	}
+	return Issuer()
+}
+
+// ClockSkew is the tolerance applied to exp, nbf and iat when validating tokens issued by other instances.
+func ClockSkew() time.Duration {
+	return config.GetEnvDuration("JWT_CLOCK_SKEW", 30*time.Second)
+}
+
+const (
+	TokenFormatJWT    = "jwt"
+	TokenFormatOpaque = "opaque"
+)
+
+// AccessTokenFormat reads TOKEN_FORMAT: self-contained JWTs by default, or opaque
+// handles that are resolved in Redis and can only be read through introspection.
+func AccessTokenFormat() string {
+	if config.GetEnv("TOKEN_FORMAT") == TokenFormatOpaque {
+		return TokenFormatOpaque
+	}
+	return TokenFormatJWT
+}
+
 func JWTExpiration() time.Duration {
 	expirationStr := config.GetEnv("JWT_EXPIRATION")
 	if expirationStr == "" {
@@ -64,6 +238,22 @@ func JWTExpiration() time.Duration {
 	return expiration
 }
 
+func RefreshTokenExpiration() time.Duration {
+	expirationStr := config.GetEnv("REFRESH_TOKEN_EXPIRATION")
+	if expirationStr == "" {
+		// Default to 7 days if not set
+		return 7 * 24 * time.Hour
+	}
+
+	expiration, err := time.ParseDuration(expirationStr)
+	if err != nil {
+		// Fallback to 7 days if parsing fails
+		return 7 * 24 * time.Hour
+	}
+
+	return expiration
+}
+
 func GenerateRefreshToken() (string, error) {
 	token := make([]byte, 32) // 32 bytes = 256 bits
 
@@ -75,3 +265,35 @@ func GenerateRefreshToken() (string, error) {
 	refreshToken := base64.URLEncoding.EncodeToString(token)
 	return refreshToken, nil
 }
+
+// AccessTokenHash computes the OpenID Connect at_hash: the left half of the access token
+// hash, using the hash function of the ID token's signing algorithm.
+func AccessTokenHash(accessToken, algorithm string) string {
+	var sum []byte
+	switch algorithm {
+	case "EdDSA":
+		digest := sha512.Sum512([]byte(accessToken))
+		sum = digest[:]
+	default:
+		digest := sha256.Sum256([]byte(accessToken))
+		sum = digest[:]
+	}
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+// GenerateRandomToken returns n random bytes encoded as unpadded base64url, safe to use in URLs.
+func GenerateRandomToken(n int) (string, error) {
+	token := make([]byte, n)
+
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of a token, used as its storage key.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/satya-nurhutama/go-oauth-boilerplate/internal/config"
+)
+
+// SupportedSigningAlgorithms lists the JWS algorithms the server can sign and verify with.
+var SupportedSigningAlgorithms = []string{"RS256", "ES256", "EdDSA"}
+
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+}
+
+func (k *SigningKey) Method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+func (k *SigningKey) PublicKey() crypto.PublicKey {
+	return k.PrivateKey.Public()
+}
+
+// KeySet holds the key used to sign new tokens and every key tokens may still be verified with.
+type KeySet struct {
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+func NewKeySet(active *SigningKey, verifyKeys ...*SigningKey) *KeySet {
+	keys := map[string]*SigningKey{active.ID: active}
+	for _, key := range verifyKeys {
+		keys[key.ID] = key
+	}
+	return &KeySet{active: active, keys: keys}
+}
+
+func (ks *KeySet) Active() *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.active
+}
+
+func (ks *KeySet) Lookup(kid string) (*SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// Without returns a copy of the set without the key kid. Without its active key the set signs
+// nothing until a new one is loaded.
+func (ks *KeySet) Without(kid string) *KeySet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	without := &KeySet{active: ks.active, keys: map[string]*SigningKey{}}
+	if ks.active != nil && ks.active.ID == kid {
+		without.active = nil
+	}
+	for id, key := range ks.keys {
+		if id != kid {
+			without.keys[id] = key
+		}
+	}
+	return without
+}
+
+func (ks *KeySet) Keys() []*SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	keys := make([]*SigningKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+var (
+	keySet     *KeySet
+	keySetMu   sync.RWMutex
+	keySetOnce sync.Once
+)
+
+// SetKeySet replaces the keys used by SignJWT and ParseJWT.
+func SetKeySet(ks *KeySet) {
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+	keySet = ks
+}
+
+// CurrentKeySet returns the configured key set, loading it from the environment on first use.
+func CurrentKeySet() *KeySet {
+	keySetOnce.Do(func() {
+		if loadedKeySet() != nil {
+			return
+		}
+
+		key, err := LoadSigningKey()
+		if err != nil {
+			log.Fatalf("Failed to load signing key: %v", err)
+		}
+		SetKeySet(NewKeySet(key))
+	})
+
+	return loadedKeySet()
+}
+
+func loadedKeySet() *KeySet {
+	keySetMu.RLock()
+	defer keySetMu.RUnlock()
+	return keySet
+}
+
+// SigningAlgorithm returns the algorithm configured for new signing keys, RS256 by default.
+func SigningAlgorithm() string {
+	if algorithm := config.GetEnv("JWT_SIGNING_ALG"); algorithm != "" {
+		return algorithm
+	}
+	return "RS256"
+}
+
+// LoadSigningKey reads the PEM key in JWT_PRIVATE_KEY_FILE, or generates an ephemeral one when it is not set.
+func LoadSigningKey() (*SigningKey, error) {
+	algorithm := SigningAlgorithm()
+
+	path := config.GetEnv("JWT_PRIVATE_KEY_FILE")
+	if path == "" {
+		log.Printf("JWT_PRIVATE_KEY_FILE is not set, generating an ephemeral %s signing key", algorithm)
+		return GenerateSigningKey(algorithm)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	privateKey, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey(algorithm, privateKey)
+}
+
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var (
+		privateKey crypto.Signer
+		err        error
+	)
+
+	switch algorithm {
+	case "RS256":
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", algorithm, err)
+	}
+
+	return NewSigningKey(algorithm, privateKey)
+}
+
+// NewSigningKey checks the key type against the algorithm and derives the kid from the JWK thumbprint.
+func NewSigningKey(algorithm string, privateKey crypto.Signer) (*SigningKey, error) {
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if algorithm != "RS256" {
+			return nil, fmt.Errorf("RSA key cannot be used with %s", algorithm)
+		}
+	case *ecdsa.PrivateKey:
+		if algorithm != "ES256" || k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ECDSA key cannot be used with %s", algorithm)
+		}
+	case ed25519.PrivateKey:
+		if algorithm != "EdDSA" {
+			return nil, fmt.Errorf("Ed25519 key cannot be used with %s", algorithm)
+		}
+	default:
+		return nil, errors.New("unsupported private key type")
+	}
+
+	jwk, err := NewJWK(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	kid, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{ID: kid, Algorithm: algorithm, PrivateKey: privateKey}, nil
+}
+
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM private key")
+	}
+
+	var (
+		key interface{}
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return signer, nil
+}
+
+func MarshalPrivateKeyPEM(privateKey crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestKeySetWithoutActiveKeySignsNothing(t *testing.T) {
+	active, err := GenerateSigningKey("ES256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := GenerateSigningKey("ES256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks := NewKeySet(active, previous).Without(active.ID)
+	if ks.Active() != nil {
+		t.Error("Without(active).Active() != nil, want no signing key")
+	}
+	if _, ok := ks.Lookup(active.ID); ok {
+		t.Error("Without(active) still verifies with the removed key")
+	}
+	if _, ok := ks.Lookup(previous.ID); !ok {
+		t.Error("Without(active) dropped another key")
+	}
+
+	original := loadedKeySet()
+	SetKeySet(ks)
+	t.Cleanup(func() { SetKeySet(original) })
+	if _, err := SignJWT(jwt.MapClaims{"sub": "1"}); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("SignJWT() = %v, want ErrNoSigningKey", err)
+	}
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// ParseRequestObject verifies a signed authorization request object (RFC 9101) against the
+// client's JWKS and returns its claims. The object must be issued by the client for this server.
+func ParseRequestObject(request string, jwks JWKS, clientID string) (jwt.MapClaims, error) {
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(request, func(token *jwt.Token) (interface{}, error) {
+		if !containsAlgorithm(AsymmetricSigningAlgorithms, token.Method.Alg()) {
+			return nil, fmt.Errorf("unsupported signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, ok := jwks.Key(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key: %q", kid)
+		}
+		return key.PublicKey()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse request object: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid request object claims")
+	}
+	if err := validateTimeClaims(claims); err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != clientID {
+		return nil, ErrInvalidIssuer
+	}
+	if !hasAudience(claims["aud"], Issuer()) {
+		return nil, ErrInvalidAudience
+	}
+	if id, ok := claims["client_id"].(string); ok && id != clientID {
+		return nil, errors.New("request object client_id does not match")
+	}
+	return claims, nil
+}
@@ -20,3 +20,18 @@ func SendResponse(c *gin.Context, code int, message string, data interface{}, er
 
 	c.JSON(code, response)
 }
+
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// SendOAuthError sends an error in the RFC 6749 format expected by OAuth clients
+func SendOAuthError(c *gin.Context, code int, oauthError, description string) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(code, OAuthErrorResponse{
+		Error:            oauthError,
+		ErrorDescription: description,
+	})
+}